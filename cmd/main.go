@@ -1,31 +1,79 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/xuri/excelize/v2"
-
 	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api/record"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/cache"
 	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
 	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/logger"
 	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/notifier"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/output"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
 	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/processor"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/recipients"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/source"
 	"go.uber.org/zap"
 )
 
-type ReportEntry struct {
-	RunNumber string `json:"run_number"`
-	NumOrdersDelivered int `json:"num_orders_delivered"`
-	NumPartsDelivered int `json:"num_parts_delivered"`
-	NumOrdersPickedUp int `json:"num_orders_picked_up"`
-	NumPartsPickedUp int `json:"num_parts_picked_up"`
-	FreightRevenue float64 `json:"freight_revenue"`
+// cliOptions holds the parsed command line flags
+type cliOptions struct {
+	kind   period.Kind
+	from   string
+	to     string
+	asOf   string
+	dryRun bool
 }
 
+func parseFlags(args []string) (*cliOptions, error) {
+	fs := flag.NewFlagSet("wcp-detrack-report", flag.ContinueOnError)
+
+	kind := fs.String("period", string(period.Auto), "reporting period: auto, week, month, quarter or custom")
+	from := fs.String("from", "", "first day of a custom period (YYYY-MM-DD)")
+	to := fs.String("to", "", "last day of a custom period, inclusive (YYYY-MM-DD)")
+	asOf := fs.String("as-of", "", "resolve the period as if today were this date (YYYY-MM-DD)")
+	dryRun := fs.Bool("dry-run", false, "print the resolved period and exit without fetching")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	opts := &cliOptions{
+		from:   *from,
+		to:     *to,
+		asOf:   *asOf,
+		dryRun: *dryRun,
+	}
+
+	// --from/--to on their own imply a custom period
+	periodSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "period" {
+			periodSet = true
+		}
+	})
+	if !periodSet && (opts.from != "" || opts.to != "") {
+		*kind = string(period.Custom)
+	}
 
+	k, err := period.ParseKind(*kind)
+	if err != nil {
+		return nil, err
+	}
+	opts.kind = k
+
+	return opts, nil
+}
 
 func main() {
 	// INIT
@@ -36,7 +84,15 @@ func main() {
 	}
 	log.Info("Starting WCP Detrack Monthly Report app...")
 
-	// init date range to report 
+	// init date range to report
+	opts, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		// The usage has already been printed
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal("Invalid arguments", zap.Error(err))
+	}
 
 	// Load Brisbane timezone
 	loc, err := time.LoadLocation("Australia/Brisbane")
@@ -44,42 +100,34 @@ func main() {
 		log.Fatal("Failed to load Brisbane location", zap.Error(err))
 	}
 
-	// Get today in Brisbane
-	today := time.Now().In(loc)
-
-	var fromDate, toDate time.Time
-	mode := ""
-
-	// -----------------------------
-	// First Monday of the month → previous month
-	// -----------------------------
-	if today.Day() <= 7 {
-		mode = "MONTH"
-		firstOfThisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
-		lastMonthEnd := firstOfThisMonth.AddDate(0, 0, -1) // last day previous month
-		lastMonthStart := time.Date(lastMonthEnd.Year(), lastMonthEnd.Month(), 1, 0, 0, 0, 0, loc)
-
-		fromDate = lastMonthStart
-		toDate = lastMonthEnd
-	} else {
-		mode = "WEEK"
-		// Previous week: Monday → Sunday
-		// Find last week's Monday
-		// today.Weekday() returns 0=Sunday, 1=Monday,...6=Saturday
-		offset := int(today.Weekday()) // Mon=1, Tue=2...
-		if offset == 0 {
-			offset = 7 // Sunday → 7 days
+	// Report relative to today in Brisbane unless --as-of is given
+	asOf := time.Now().In(loc)
+	if opts.asOf != "" {
+		asOf, err = time.ParseInLocation(period.DateLayout, opts.asOf, loc)
+		if err != nil {
+			log.Fatal("Invalid --as-of date", zap.String("asOf", opts.asOf), zap.Error(err))
 		}
+	}
 
-		// Last week's Monday = today - offset - 6
-		lastMonday := today.AddDate(0, 0, -offset-6)
-		lastSunday := lastMonday.AddDate(0, 0, 6)
-
-		fromDate = time.Date(lastMonday.Year(), lastMonday.Month(), lastMonday.Day(), 0, 0, 0, 0, loc)
-		toDate = time.Date(lastSunday.Year(), lastSunday.Month(), lastSunday.Day(), 23, 59, 59, 0, loc)
+	reportPeriod, err := period.Resolve(opts.kind, asOf, opts.from, opts.to, loc)
+	if err != nil {
+		log.Fatal("Failed to resolve reporting period", zap.Error(err))
 	}
+	// toDate is exclusive; lastDate is the last day shown in labels
+	fromDate, toDate, lastDate := reportPeriod.From, reportPeriod.To, reportPeriod.LastDay()
+
+	log.Info("Resolved reporting period",
+		zap.String("mode", strings.ToUpper(string(reportPeriod.Kind))),
+		zap.String("asOf", asOf.Format(period.DateLayout)),
+		zap.String("from", fromDate.Format(period.DateLayout)),
+		zap.String("to", lastDate.Format(period.DateLayout)),
+		zap.Int("days", reportPeriod.Days()),
+	)
 
-	log.Info((fmt.Sprintf("MODE: %s, RANGE: %s - %s", mode, fromDate.Format("2006-01-02"), toDate.Format("2006-01-02"))))
+	if opts.dryRun {
+		log.Info("Dry run: nothing fetched from Detrack")
+		return
+	}
 
 	// init config
 	cfg, err := config.LoadConfig()
@@ -90,224 +138,515 @@ func main() {
 	// init Detrack client
 	detrackClient := api.NewDetrackClient(log, cfg)
 
-	// init Notifier
-	notifier := notifier.NewNotifier(log, cfg)
-
-	// init Excel file 
-	f := excelize.NewFile()
-    defer func() {
-        if err := f.Close(); err != nil {
-            fmt.Println(err)
-        }
-    }()
-	
-	// MAIN
-	jobs, err := detrackClient.GetJobs() // e.g., limit 1000
+	// Record the Detrack responses as fixtures (API key and personal data redacted) for replaying offline
+	if cfg.RecordDir != "" {
+		recorder, err := record.NewRecorder(cfg.RecordDir, cfg.BaseURL, cfg.APIKey, cfg.RecordScrub, detrackClient.HTTPClient.Transport)
+		if err != nil {
+			log.Fatal("Failed to start recording Detrack responses", zap.Error(err))
+		}
+		detrackClient.HTTPClient.Transport = recorder
+		log.Info("Recording Detrack responses", zap.String("dir", cfg.RecordDir))
+	}
+
+	// init local job cache, so only the days that may still change are fetched again
+	if cfg.CacheMode != config.CacheOff {
+		jobCache, err := cache.Open(log, cfg.CacheDir, cfg.CacheMode, cfg.CacheSettleDays, cfg.CacheRefreshDays)
+		if err != nil {
+			log.Fatal("Failed to open job cache", zap.Error(err))
+		}
+		detrackClient.Cache = jobCache
+	}
+
+	// Report from Detrack unless a captured snapshot is given
+	var jobSource source.JobSource = source.NewAPISource(detrackClient)
+	if cfg.JobsFile != "" {
+		jobSource = source.NewFileSource(log, cfg.JobsFile)
+	}
+	log.Info("Reading jobs from", zap.String("source", jobSource.Name()))
+
+	// init run number normalizer, failing fast on a bad rules file
+	normalizerRules, err := processor.LoadRules(cfg.NormalizerRules)
 	if err != nil {
-		log.Fatal("Failed to fetch jobs", zap.Error(err))
+		log.Fatal("Failed to load normalizer rules", zap.Error(err))
 	}
 
-	// Preprocess jobs - normalize run numbers
-	normalizer := processor.NewRunNumberNormalizer()
-	for i := range jobs {
-		jobs[i].RunNumber = normalizer.Normalize(jobs[i].RunNumber)
+	normalizer, err := processor.NewRunNumberNormalizer(normalizerRules)
+	if err != nil {
+		log.Fatal("Failed to init run number normalizer", zap.Error(err))
 	}
 
-	log.Info("Total jobs fetched", zap.Int("count", len(jobs)))
+	// init Notifier. With only recipient groups the full report is saved but not sent.
+	var notify *notifier.Notifier
+	if cfg.NotifyChannels != "" || cfg.EmailReceivers != "" {
+		notify, err = notifier.NewNotifier(log, cfg)
+		if err != nil {
+			log.Fatal("Failed to init notify channels", zap.Error(err))
+		}
+	}
 
-	// All Jobs sheet
-	jobSheet := "Jobs"
+	groups, err := recipients.LoadGroups(cfg.RecipientGroups)
+	if err != nil {
+		log.Fatal("Failed to load recipient groups", zap.Error(err))
+	}
 
-	_, err = f.NewSheet(jobSheet)
+	// init report writers, failing fast on an unknown OUTPUT_FORMATS or JOBS_COLUMNS entry
+	os.Mkdir("./data", 0755)
+
+	columnNames := cfg.JobsColumns
+	if len(columnNames) == 0 {
+		columnNames = output.DefaultJobColumns
+	}
+	jobColumns, err := output.NewJobColumns(columnNames)
 	if err != nil {
-		log.Fatal("Failed to create 'Jobs' sheet", zap.Error(err))
-	}
-
-	// Create Jobs headers
-	jobHeaders := []string{
-		"id",
-		"status",
-		"date",
-		"type",
-		"items_count",
-		"job_price",
-		"do_number",
-		"run_number",
-	}
-
-	for i, h := range jobHeaders {
-		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
-		f.SetCellValue(jobSheet, cell, h)
-	}
-
-	// Pour Jobs data
-	jobStartRow := 2
-	for _, job := range jobs {
-		f.SetCellValue(jobSheet, fmt.Sprintf("A%d", jobStartRow), job.ID)
-		f.SetCellValue(jobSheet, fmt.Sprintf("B%d", jobStartRow), job.Status)
-		f.SetCellValue(jobSheet, fmt.Sprintf("C%d", jobStartRow), job.Date)
-		f.SetCellValue(jobSheet, fmt.Sprintf("D%d", jobStartRow), job.Type)
-		f.SetCellValue(jobSheet, fmt.Sprintf("E%d", jobStartRow), job.ItemCount)
-		f.SetCellValue(jobSheet, fmt.Sprintf("F%d", jobStartRow), job.JobPrice)
-		f.SetCellValue(jobSheet, fmt.Sprintf("G%d", jobStartRow), job.DoNumber)
-		f.SetCellValue(jobSheet, fmt.Sprintf("H%d", jobStartRow), job.RunNumber)
-		jobStartRow++
-	} 
-
-	// Report Sheet
+		log.Fatal("Invalid JOBS_COLUMNS", zap.Error(err), zap.Strings("available", output.JobColumnNames()))
+	}
+
+	reportName := fmt.Sprintf("detrack_report_%s_to_%s",
+		fromDate.Format("2006-01-02"),
+		lastDate.Format("2006-01-02"),
+	)
+
+	// MAIN
+	// Stop fetching (including retry waits) when ECS or the terminal stops the task
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	status := "completed"
 
-	log.Info(fmt.Sprintf("Processing jobs with Status: %s (%s - %s)\n", status, fromDate, toDate))
+	// Process the jobs in a single pass as Detrack pages arrive: normalize the
+	// run number, then for every report variant the job belongs to write it,
+	// aggregate it into every pivot and keep an independent revenue total for
+	// reconciliation. Nothing keeps every job.
+	log.Info(fmt.Sprintf("Processing jobs with Status: %s (%s)", status, reportPeriod), zap.String("reports", cfg.ReportMode))
 
-	// Aggregate report by run_number
+	aggOpts := report.Options{Status: status, PricePolicy: cfg.PricePolicy}
+	filters := api.JobFilters{Status: status}
 
-	reportMap := make(map[string]*ReportEntry)
+	// One summary sheet per pivot: the built-in ones REPORT_MODE asks for, then the PIVOTS file
+	pivots := []report.Pivot{}
+	if cfg.ReportMode != config.ReportDrivers {
+		pivots = append(pivots, report.RunPivot)
+	}
+	if cfg.ReportMode != config.ReportRuns {
+		pivots = append(pivots, report.DriverPivot)
+	}
+	customPivots, err := report.LoadPivots(cfg.Pivots)
+	if err != nil {
+		log.Fatal("Failed to load pivots", zap.Error(err))
+	}
+	pivots = append(pivots, customPivots...)
+
+	dimensions := report.NewDimensions(func(runNumber string) (string, string) {
+		result := normalizer.Resolve(runNumber)
+		return result.Route, result.Time
+	})
+
+	groupBys := make([]report.GroupBy, len(pivots))
+	metrics := make([][]report.Metric, len(pivots))
+	summaryNames := make([]string, len(pivots))
+	for i, pivot := range pivots {
+		groupBys[i], metrics[i], err = pivot.Resolve(dimensions)
+		if err != nil {
+			log.Fatal("Invalid pivot", zap.Error(err))
+		}
+		summaryNames[i] = pivot.Name
+	}
+	if err := output.CheckSummaryNames(summaryNames); err != nil {
+		log.Fatal("Invalid pivot", zap.Error(err))
+	}
 
-	for _, job := range jobs {
-		// Filter by status
-		if job.Status != status {
+	// The full report, then one per recipient group scheduled for this kind of period
+	variants := []*reportVariant{{notify: notify}}
+	for _, group := range groups {
+		if !group.Scheduled(reportPeriod.Kind) {
+			log.Info("Recipient group not scheduled for this period", zap.String("group", group.Name), zap.Strings("schedule", kindNames(group.Schedule)))
 			continue
 		}
 
-		// filter by date
-		formattedJobDate, err := time.ParseInLocation(
-			"2006-01-02",
-			job.Date,
-			loc,
-		)
-		if err != nil ||
-			formattedJobDate.Before(fromDate) ||
-			!formattedJobDate.Before(toDate) {
-			continue
+		match, err := group.Filter.Match(dimensions)
+		if err != nil {
+			log.Fatal("Invalid recipient group", zap.String("group", group.Name), zap.Error(err))
+		}
+		if err := output.CheckSheets(group.Sheets, summaryNames); err != nil {
+			log.Fatal("Invalid recipient group", zap.String("group", group.Name), zap.Error(err))
+		}
+		groupNotify, err := notifier.NewNotifierWithChannels(log, cfg, group.Channels)
+		if err != nil {
+			log.Fatal("Failed to init recipient group channels", zap.String("group", group.Name), zap.Error(err))
+		}
+
+		variants = append(variants, &reportVariant{name: group.Name, match: match, sheets: group.Sheets, notify: groupNotify})
+	}
+
+	for _, v := range variants {
+		variantOpts := aggOpts
+		variantOpts.Match = v.match
+
+		for i, pivot := range pivots {
+			if !v.includes(pivot.Name) {
+				continue
+			}
+
+			pivotOpts := variantOpts
+			pivotOpts.GroupBy = groupBys[i]
+			if pivot.CountsFailed() {
+				pivotOpts.FailedStatus = "failed"
+
+				// Failed jobs are needed too, so fetch every status
+				filters.Status = ""
+			}
+
+			// The compared pivots only count completed jobs
+			if pivot.Compare {
+				v.compareOpts = append(v.compareOpts, report.Options{Status: status, PricePolicy: cfg.PricePolicy, GroupBy: groupBys[i], Match: v.match})
+			}
+
+			v.pivots = append(v.pivots, pivot)
+			v.summaries = append(v.summaries, output.Summary{Name: pivot.Name, Metrics: metrics[i]})
+			v.aggregators = append(v.aggregators, report.NewAggregator(reportPeriod, pivotOpts))
 		}
 
-		// Append 
-		entry, isExists := reportMap[job.RunNumber]
-		freightFloat64, err := strconv.ParseFloat(job.JobPrice, 64)
+		name := reportName
+		if v.name != "" {
+			name += "_" + v.name
+		}
+		v.writers, err = output.NewWriters(cfg.OutputFormats, "./data", name, jobColumns)
 		if err != nil {
-			log.Error("Failed to parse Job Price. Fallback to 0",
-				zap.String("jobID", job.ID),
-				zap.Error(err),
-			)
-			freightFloat64 = 0
+			log.Fatal("Failed to init report writers", zap.Error(err))
 		}
+		v.writeJobs = v.includes("Jobs")
+
+		v.reconciler = report.NewReconciler(reportPeriod, aggOpts, jobColumns.Headers())
+		// Keep track of the run numbers no rule matched and the jobs that had no run number at all
+		v.unmapped = processor.NewUnmappedCollector(5)
+		v.unassigned = []processor.UnassignedJob{}
+	}
 
-		if !isExists {
-			entry = &ReportEntry{
-				RunNumber: job.RunNumber,
-				FreightRevenue: freightFloat64, 
+	fetchedCount, jobCount := 0, 0
+
+	// Only fetch the reporting range; the status filter is applied by the source too
+	stream := jobSource.Jobs(ctx, fromDate, toDate, filters)
+	defer stream.Close()
+
+	for stream.Next() {
+		job := stream.Job()
+		fetchedCount++
+
+		// Jobs with another status were only fetched to count the failures
+		if job.Status != status {
+			job.RunNumber = normalizer.ResolveJob(job).Value
+			for _, v := range variants {
+				for i, pivot := range v.pivots {
+					// Failed jobs are only counted, so their price is not needed
+					if pivot.CountsFailed() {
+						v.aggregators[i].Add(job, report.Amounts{})
+					}
+				}
+			}
+			continue
+		}
+		jobCount++
+
+		result := normalizer.ResolveJob(job)
+		job.RunNumber = result.Value
+
+		// Parse the amounts once for every aggregator, so a bad price is logged once
+		amounts := report.ParseAmounts(log, job, cfg.PricePolicy)
+
+		for _, v := range variants {
+			if v.match != nil && !v.match(job) {
+				continue
+			}
+
+			v.unmapped.Record(result, job.DoNumber)
+			if result.Rule == processor.MatchInferred || result.Rule == processor.MatchEmpty {
+				v.unassigned = append(v.unassigned, processor.NewUnassignedJob(job, result))
+			}
+
+			for _, aggregator := range v.aggregators {
+				if err := aggregator.Add(job, amounts); err != nil {
+					log.Fatal("Failed to aggregate report (PRICE_POLICY is reject)", zap.Error(err))
+				}
+			}
+
+			if v.writeJobs {
+				for _, w := range v.writers {
+					if err := w.WriteJob(job); err != nil {
+						log.Fatal("Failed to write job", zap.String("jobID", job.ID), zap.Error(err))
+					}
+				}
 			}
 
-			reportMap[job.RunNumber] = entry
+			v.reconciler.AddRow(jobColumns.Record(job))
 		}
+	}
 
-		if job.Type == "Delivery" {
-			entry.NumOrdersDelivered++
-			entry.NumPartsDelivered += int(job.ItemCount)
-		} else {
-			entry.NumOrdersPickedUp++
-			entry.NumPartsPickedUp += int(job.ItemCount)
+	if err := stream.Err(); err != nil {
+		// Tell the receivers why there is no report this time before giving up
+		alertSubject := "WCP Detrack Monthly Report FAILED"
+		alertBody := fmt.Sprintf(
+			"Hi,\n\nThe Detrack report for %s to %s could not be produced from %s.\n\nReason: %s\n\nDetails: %v\n\nThanks",
+			fromDate.Format("2006-01-02"),
+			lastDate.Format("2006-01-02"),
+			jobSource.Name(),
+			fetchFailureReason(err),
+			err,
+		)
+		for _, v := range variants {
+			if v.notify == nil {
+				continue
+			}
+			if sendErr := v.notify.Send(notifier.Message{Subject: alertSubject, Text: alertBody}); sendErr != nil {
+				log.Error("Failed to send failure email", zap.String("group", v.name), zap.Error(sendErr))
+			}
 		}
-		entry.FreightRevenue += freightFloat64
+
+		log.Fatal("Failed to fetch jobs", zap.Error(err))
 	}
 
+	log.Info("Total jobs fetched", zap.Int("count", fetchedCount), zap.Int(status, jobCount))
 
-	// Convert map to slice an sort
-	var reportSlice []ReportEntry
-	for _, entry := range reportMap {
-		reportSlice = append(reportSlice, *entry)
+	// Build the requested reports
+	for _, v := range variants {
+		for i, aggregator := range v.aggregators {
+			v.summaries[i].Report = aggregator.Report()
+		}
 	}
 
-	// Report sheet
-	reportSheet := "Report"
-	_, err = f.NewSheet(reportSheet)
-	if err != nil {
-		log.Fatal("Failed to create 'Report' sheet", zap.Error(err))
+	// Run the compared pivots again over the earlier periods, for every
+	// variant in one pass. A comparison that cannot be built is left out
+	// rather than holding back the report.
+	compareOpts := []report.Options{}
+	for _, v := range variants {
+		compareOpts = append(compareOpts, v.compareOpts...)
 	}
+	if len(compareOpts) > 0 {
+		for _, name := range cfg.Compare {
+			baselinePeriod := reportPeriod.Previous()
+			if name == config.CompareLastYear {
+				baselinePeriod = reportPeriod.YearAgo()
+			}
+			log.Info("Comparing with an earlier period", zap.String("compare", name), zap.Stringer("period", baselinePeriod))
 
-	// Delete default Sheet1 and set Report as active
-	f.DeleteSheet("Sheet1")
-	reportSheetIndex, err := f.GetSheetIndex(reportSheet)
-	if err != nil {
-		log.Error("Failed to get report sheet index", zap.Error(err))
+			reports, err := aggregatePeriod(ctx, log, jobSource, normalizer, baselinePeriod, compareOpts)
+			if err != nil {
+				log.Warn("Failed to build the comparison, leaving it out", zap.String("compare", name), zap.Error(err))
+				continue
+			}
+
+			for _, v := range variants {
+				for i, pivot := range v.pivots {
+					if pivot.Compare {
+						v.summaries[i].Baselines = append(v.summaries[i].Baselines, report.NewBaseline(name, reports[0]))
+						reports = reports[1:]
+					}
+				}
+			}
+		}
+	}
+
+	for _, v := range variants {
+		sendReport(log, cfg, jobSource.Name(), reportPeriod, v)
+	}
+
+	log.Info("COMPLETED!")
+}
+
+// reportVariant is one version of the report with its own files and
+// receivers: the full report, or the filtered one of a recipient group
+type reportVariant struct {
+	name   string             // the recipient group; empty for the full report
+	match  func(api.Job) bool // nil keeps every job
+	sheets []string           // nil writes every sheet
+	notify *notifier.Notifier // nil when nobody is sent this version
+
+	pivots      []report.Pivot
+	summaries   []output.Summary
+	aggregators []*report.Aggregator
+	compareOpts []report.Options
+	writers     []output.ReportWriter
+	writeJobs   bool
+	reconciler  *report.Reconciler
+	unmapped    *processor.UnmappedCollector
+	unassigned  []processor.UnassignedJob
+}
+
+// includes reports whether the variant has the named sheet
+func (v *reportVariant) includes(sheet string) bool {
+	doc := output.Document{Sheets: v.sheets}
+	return doc.Includes(sheet)
+}
+
+// sendReport checks a variant's totals, saves its files and sends them to its receivers
+func sendReport(log *zap.Logger, cfg *config.Config, sourceName string, reportPeriod period.Period, v *reportVariant) {
+	if v.name != "" {
+		log = log.With(zap.String("group", v.name))
+	}
+
+	unmappedRuns := v.unmapped.Runs()
+	if len(unmappedRuns) > 0 {
+		log.Warn("Run numbers not matched by any normalizer rule", zap.Int("distinct", len(unmappedRuns)))
+	}
+	if len(v.unassigned) > 0 {
+		log.Warn("Jobs without a run number", zap.Int("count", len(v.unassigned)))
+	}
+
+	// rpt is the report the checks below run on
+	doc := &output.Document{
+		Period:     reportPeriod,
+		Summaries:  v.summaries,
+		Unmapped:   unmappedRuns,
+		Unassigned: v.unassigned,
+		Sheets:     v.sheets,
+	}
+	rpt := doc.Primary()
+
+	excludedFields := []zap.Field{zap.Int("included", rpt.Included)}
+	for _, reason := range report.ExclusionReasons {
+		excludedFields = append(excludedFields, zap.Int(string(reason), rpt.Excluded[reason]))
+	}
+	log.Info("Jobs excluded from report by reason", excludedFields...)
+
+	if len(rpt.PriceIssues) > 0 {
+		log.Warn("Jobs with unparseable job_price",
+			zap.Int("count", len(rpt.PriceIssues)),
+			zap.String("policy", cfg.PricePolicy),
+		)
+	}
+
+	// Reconcile the report total against the Jobs sheet
+	reconciliation := v.reconciler.Result(rpt)
+
+	reconciled := reconciliation.Matches()
+	reconcileFields := []zap.Field{
+		zap.Int("jobs", reconciliation.Jobs),
+		zap.String("sheetRevenue", reconciliation.SheetRevenue.String()),
+		zap.String("reportRevenue", reconciliation.ReportRevenue.String()),
+	}
+	if reconciled {
+		log.Info("Report revenue reconciled with Jobs sheet", reconcileFields...)
 	} else {
-		f.SetActiveSheet(reportSheetIndex)
-	}
-
-	reportHeaders := []string {
-		"run_number",
-		"num_orders_delivered",
-		"num_parts_delivered",
-		"num_orders_picked_up",
-		"num_parts_picked_up",
-		"freight_revenue",
-	}
-
-	for i, h := range reportHeaders {
-		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
-		f.SetCellValue(reportSheet, cell, h)
-	}
-
-	// Pour Report data
-	totalNumOrdersDelivered := 0
-	totalNumPartsDelivered := 0
-	totalNumOrdersPickedUp := 0
-	totalNumPartsPickedUp := 0
-	totalFreightRevenue := 0.0
-
-	reportStartRow := 2
-	for _, reportEntry := range reportSlice {
-		totalNumOrdersDelivered += reportEntry.NumOrdersDelivered
-		totalNumPartsDelivered += reportEntry.NumPartsDelivered
-		totalNumOrdersPickedUp += reportEntry.NumOrdersPickedUp
-		totalNumPartsPickedUp += reportEntry.NumPartsPickedUp
-		totalFreightRevenue += reportEntry.FreightRevenue
-
-		f.SetCellValue(reportSheet, fmt.Sprintf("A%d", reportStartRow), reportEntry.RunNumber)
-		f.SetCellValue(reportSheet, fmt.Sprintf("B%d", reportStartRow), reportEntry.NumOrdersDelivered)
-		f.SetCellValue(reportSheet, fmt.Sprintf("C%d", reportStartRow), reportEntry.NumPartsDelivered)
-		f.SetCellValue(reportSheet, fmt.Sprintf("D%d", reportStartRow), reportEntry.NumOrdersPickedUp)
-		f.SetCellValue(reportSheet, fmt.Sprintf("E%d", reportStartRow), reportEntry.NumPartsPickedUp)
-		f.SetCellValue(reportSheet, fmt.Sprintf("F%d", reportStartRow), reportEntry.FreightRevenue)
-		reportStartRow++
-	}
-
-	// Add total values 
-	f.SetCellValue(reportSheet, fmt.Sprintf("A%d", reportStartRow), "TOTAL")
-	f.SetCellValue(reportSheet, fmt.Sprintf("B%d", reportStartRow), totalNumOrdersDelivered)
-	f.SetCellValue(reportSheet, fmt.Sprintf("C%d", reportStartRow), totalNumPartsDelivered)
-	f.SetCellValue(reportSheet, fmt.Sprintf("D%d", reportStartRow), totalNumOrdersPickedUp)
-	f.SetCellValue(reportSheet, fmt.Sprintf("E%d", reportStartRow), totalNumPartsPickedUp)
-	f.SetCellValue(reportSheet, fmt.Sprintf("F%d", reportStartRow), totalFreightRevenue)
-
-	// Save xlsx file 
-	os.Mkdir("./data", 0755)
+		log.Error("Report revenue does not match Jobs sheet", reconcileFields...)
+		if cfg.ReconcileMode == config.ReconcileFail {
+			log.Fatal("Aborting: reconciliation failed and RECONCILE_MODE is fail")
+		}
+	}
 
-	reportPath := fmt.Sprintf("./data/detrack_report_%s_to_%s.xlsx",
-		fromDate.Format("2006-01-02"),
-		toDate.Format("2006-01-02"),
-	)
+	// Save report files
+	var reportPaths []string
+	for _, w := range v.writers {
+		paths, err := w.Finish(doc)
+		if err != nil {
+			log.Fatal("Failed to save report", zap.Error(err))
+		}
+		reportPaths = append(reportPaths, paths...)
+	}
 
-	if err := f.SaveAs(reportPath); err != nil {
-        log.Error("Failed to save XLSX file", zap.Error(err))
-    }
+	log.Info("Report generated successfully", zap.Strings("files", reportPaths))
 
-	log.Info("XLSX report generated successfully")
+	if v.notify == nil {
+		return
+	}
 
-	// Send email 
+	// Send email
 	subject := "WCP Detrack Monthly Report Notification"
-	body := fmt.Sprintf(
-		"Hi,\n\nAttached is the report for Detrack from %s to %s.\n\nThanks",
-		fromDate.Format("2006-01-02"),
-		toDate.Format("2006-01-02"),
-	)
+	if v.name != "" {
+		subject += " (" + v.name + ")"
+	}
+	reportEmail := notifier.NewReportEmail(doc, cfg.CompareMoverPercent)
+
+	// Make it obvious a report was not built from live Detrack data
+	if cfg.JobsFile != "" {
+		subject = "[SNAPSHOT] " + subject
+		reportEmail.Notices = append(reportEmail.Notices, fmt.Sprintf("NOTE: this report was built from the %s, not from live Detrack data.", sourceName))
+	}
+
+	if !reconciled {
+		subject = "[CHECK TOTALS] " + subject
+		warning := fmt.Sprintf(
+			"WARNING: the Report sheet freight revenue (%s) does not match the total recomputed from the Jobs sheet (%s), a difference of %s. Please check before using these numbers.",
+			reconciliation.ReportRevenue,
+			reconciliation.SheetRevenue,
+			reconciliation.Difference(),
+		)
+		reportEmail.Notices = append([]string{warning}, reportEmail.Notices...)
+	}
 
+	text, html, err := reportEmail.Render()
+	if err != nil {
+		log.Fatal("Failed to render report email", zap.Error(err))
+	}
 
-	if err := notifier.Send(subject, body, []string{reportPath}); err != nil {
-		log.Error("Failed to send report email", zap.Error(err))
+	msg := notifier.Message{Subject: subject, Text: text, HTML: html, Report: reportEmail, Files: reportPaths}
+	if err := v.notify.Send(msg); err != nil {
+		log.Error("Failed to send report", zap.Error(err))
 	} else {
-		log.Info("Report email sent successfully")
+		log.Info("Report sent successfully")
 	}
+}
 
-	log.Info("COMPLETED!")
-}
\ No newline at end of file
+// kindNames lists period kinds for logging
+func kindNames(kinds []period.Kind) []string {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = string(kind)
+	}
+	return names
+}
+
+// aggregatePeriod fetches the completed jobs of another period and aggregates
+// them once per option, e.g. for the previous month of a comparison
+func aggregatePeriod(ctx context.Context, log *zap.Logger, jobSource source.JobSource, normalizer *processor.RunNumberNormalizer, p period.Period, options []report.Options) ([]*report.Report, error) {
+	aggregators := make([]*report.Aggregator, len(options))
+	for i, opts := range options {
+		aggregators[i] = report.NewAggregator(p, opts)
+	}
+
+	stream := jobSource.Jobs(ctx, p.From, p.To, api.JobFilters{Status: options[0].Status})
+	defer stream.Close()
+
+	for stream.Next() {
+		job := stream.Job()
+		job.RunNumber = normalizer.ResolveJob(job).Value
+		amounts := report.ParseAmounts(log, job, options[0].PricePolicy)
+
+		for _, aggregator := range aggregators {
+			if err := aggregator.Add(job, amounts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	reports := make([]*report.Report, len(aggregators))
+	for i, aggregator := range aggregators {
+		reports[i] = aggregator.Report()
+	}
+	return reports, nil
+}
+
+// fetchFailureReason explains a Detrack fetch error for the failure email
+func fetchFailureReason(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "the run was stopped before all jobs were fetched."
+	case errors.Is(err, api.ErrUnauthorized):
+		return "Detrack rejected the API key. Check API_KEY in Secrets Manager."
+	case errors.Is(err, api.ErrRateLimited):
+		return "Detrack kept rate limiting the requests. The report can be re-run later with the same --period/--as-of."
+	case errors.Is(err, api.ErrUnavailable):
+		return "Detrack could not be reached or returned server errors after several retries. Re-run later."
+	case errors.Is(err, api.ErrDecode):
+		return "Detrack returned a response that could not be read."
+	case errors.Is(err, api.ErrUnexpectedStatus):
+		return "Detrack returned an unexpected error response."
+	case errors.Is(err, cache.ErrNotCached):
+		return "CACHE_MODE is offline and the local job cache does not have every day of the period. Run once with CACHE_MODE=sync."
+	case errors.Is(err, os.ErrNotExist):
+		return "the JOBS_FILE snapshot does not exist."
+	default:
+		return "fetching jobs from Detrack failed."
+	}
+}
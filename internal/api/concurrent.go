@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultFetchConcurrency is used when FetchConcurrency is not set
+const defaultFetchConcurrency = 1
+
+// dayResult is the outcome of fetching every page of one day
+type dayResult struct {
+	jobs   []Job
+	cached bool
+	err    error
+}
+
+// fetchDays fetches each day with up to FetchConcurrency days in flight and
+// hands them to yield in calendar order. A day is only started once fewer
+// than FetchConcurrency days are fetching or waiting for yield, so memory
+// stays bounded however far ahead the workers could run.
+func (c *DetrackClient) fetchDays(ctx context.Context, days []time.Time, filters JobFilters, yield func(day time.Time, jobs []Job) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := c.FetchConcurrency
+	if concurrency < 1 {
+		concurrency = defaultFetchConcurrency
+	}
+
+	slots := make([]chan dayResult, len(days))
+	for i := range slots {
+		slots[i] = make(chan dayResult, 1)
+	}
+	tokens := make(chan struct{}, concurrency)
+
+	// Dispatcher: start days in order while tokens are available
+	go func() {
+		for i, day := range days {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(slot chan<- dayResult, day time.Time) {
+				jobs, cached, err := c.fetchDay(ctx, day, filters)
+				slot <- dayResult{jobs: jobs, cached: cached, err: err}
+			}(slots[i], day)
+		}
+	}()
+
+	// Consumer: wait for each day in order, releasing its token once handled
+	for i, day := range days {
+		var result dayResult
+		select {
+		case result = <-slots[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if result.err != nil {
+			return fmt.Errorf("failed to fetch jobs for %s: %w", day.Format(dateLayout), result.err)
+		}
+
+		if err := yield(day, result.jobs); err != nil {
+			return err
+		}
+		<-tokens
+
+		c.Logger.Info("Fetched day",
+			zap.String("date", day.Format(dateLayout)),
+			zap.Int("jobs", len(result.jobs)),
+			zap.Bool("cached", result.cached),
+			zap.String("progress", fmt.Sprintf("%d/%d", i+1, len(days))),
+		)
+	}
+
+	return nil
+}
+
+// fetchDay returns the jobs of one day from the cache when it has them,
+// otherwise fetches every page from Detrack and saves them to the cache
+func (c *DetrackClient) fetchDay(ctx context.Context, day time.Time, filters JobFilters) ([]Job, bool, error) {
+	if c.Cache != nil {
+		jobs, ok, err := c.Cache.Load(day, filters)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return jobs, true, nil
+		}
+	}
+
+	jobs, err := c.fetchPages(ctx, c.jobsURL(day, filters))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if c.Cache != nil {
+		if err := c.Cache.Save(day, filters, jobs); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return jobs, false, nil
+}
@@ -1,38 +1,138 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
 	"go.uber.org/zap"
 )
 
 type Job struct {
-	ID 			string `json:"id"`
-	Status 		string `json:"status"` // 	Job Status. completed
-	Date 		string `json:"date"` // Date for performing the job. 2019-12-24
-	Type        string `json:"type"` // Detrack Job Type Delivery/Collection. Delivery
-	ItemCount 		float32 `json:"items_count"` // Number of entries in the Item Details list. 10
-	JobPrice 		string `json:"job_price"` // Price of the job. "10.34"
-	// TotalPrice 		string `json:"total_price"` // Total price amount for the job. 100
-	// InvoiceAmount 		float32 `json:"invoice_amount"` // The amount for the job invoice. 1.5
-	// PaymentAmount 		float32 `json:"payment_amount"` // The amount to be collected for the job. 1.5
-	DoNumber 	string `json:"do_number"` // Unique identifier for the job. DO123
-	// InvoiceNumber 		string `json:"invoice_number"` // The invoice number of the job. Inv123
-	RunNumber   string `json:"run_number"` // The run number which the job belongs to. 1
+	ID               string    `json:"id"`
+	Status           string    `json:"status"`                  // 	Job Status. completed
+	Date             string    `json:"date"`                    // Date for performing the job. 2019-12-24
+	Type             string    `json:"type"`                    // Detrack Job Type Delivery/Collection. Delivery
+	ItemCount        float32   `json:"items_count"`             // Number of entries in the Item Details list. 10
+	JobPrice         string    `json:"job_price"`               // Price of the job. "10.34"
+	TotalPrice       string    `json:"total_price"`             // Total price amount for the job. 100
+	RawInvoiceAmount RawAmount `json:"invoice_amount"`          // The amount for the job invoice. 1.5
+	RawPaymentAmount RawAmount `json:"payment_amount"`          // The amount to be collected for the job. 1.5
+	DoNumber         string    `json:"do_number"`               // Unique identifier for the job. DO123
+	InvoiceNumber    string    `json:"invoice_number"`          // The invoice number of the job. Inv123
+	RunNumber        string    `json:"run_number"`              // The run number which the job belongs to. 1
+	TimeWindow       string    `json:"time_window"`             // Time window for the job. 09:00 - 12:00
+	AssignTo         string    `json:"assign_to"`               // Vehicle/driver the job is assigned to. Driver 1
+	Address          string    `json:"address"`                 // Delivery/collection address. 1 Main St
+	Customer         string    `json:"deliver_to_collect_from"` // Person or company receiving/handing over the goods. John
+	PodTime          string    `json:"pod_time"`                // When the proof of delivery was captured, i.e. completion time. 2019-12-24T10:15:00+08:00
+	ReceivedBy       string    `json:"received_by_sent_by"`     // Name given on the proof of delivery. Jane
+	Reason           string    `json:"reason"`                  // Reason given when the job failed. Customer not home
 }
 
+// Price parses JobPrice into an exact AUD amount.
+// A blank job_price means no price was set and counts as 0.
+func (j Job) Price() (money.Cents, error) {
+	if strings.TrimSpace(j.JobPrice) == "" {
+		return 0, nil
+	}
+	return money.Parse(j.JobPrice)
+}
+
+// TotalPriceAmount parses TotalPrice like Price parses JobPrice
+func (j Job) TotalPriceAmount() (money.Cents, error) {
+	if strings.TrimSpace(j.TotalPrice) == "" {
+		return 0, nil
+	}
+	return money.Parse(j.TotalPrice)
+}
+
+// InvoiceAmount parses RawInvoiceAmount like Price parses JobPrice
+func (j Job) InvoiceAmount() (money.Cents, error) {
+	return j.RawInvoiceAmount.Cents()
+}
+
+// PaymentAmount parses RawPaymentAmount like Price parses JobPrice
+func (j Job) PaymentAmount() (money.Cents, error) {
+	return j.RawPaymentAmount.Cents()
+}
+
+// RawAmount is an amount as Detrack sent it. Detrack sends numbers (1.5) or
+// strings ("1.50"); both are kept as text, so a bad value is handled by
+// PRICE_POLICY instead of failing the whole page.
+type RawAmount string
+
+// UnmarshalJSON keeps a string's text or a number's digits; null is blank
+func (a *RawAmount) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	switch {
+	case text == "null":
+		*a = ""
+	case strings.HasPrefix(text, `"`):
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*a = RawAmount(s)
+	case strings.ContainsAny(text, "eE"):
+		// Exponent numbers such as 1e-3 are written out as decimals for money.Parse
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			text = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		*a = RawAmount(text)
+	default:
+		*a = RawAmount(text)
+	}
+	return nil
+}
+
+// Cents parses the amount; a blank amount counts as 0
+func (a RawAmount) Cents() (money.Cents, error) {
+	if strings.TrimSpace(string(a)) == "" {
+		return 0, nil
+	}
+	return money.Parse(string(a))
+}
+
+// dateLayout is the date format Detrack uses for job dates and the date filter
+const dateLayout = "2006-01-02"
+
 // DetrackClient handle API requests
 type DetrackClient struct {
-	BaseURL 	string
-	APIKey 		string
-	FetchLimit  int
-	HTTPClient 	*http.Client
-	Logger 		*zap.Logger
+	BaseURL    string
+	APIKey     string
+	FetchLimit int
+	HTTPClient *http.Client
+	Logger     *zap.Logger
+
+	// Number of days fetched at the same time by GetJobsInRange
+	FetchConcurrency int
+
+	// Retries for timeouts, transport errors, 429 and 5xx responses
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Longest Retry-After waited for; a 429 asking for longer fails with ErrRateLimited
+	RetryAfterLimit time.Duration
+
+	// Optional store of days already fetched; nil fetches every day
+	Cache DayCache
+}
+
+// DayCache keeps the jobs of days already fetched, so GetJobsInRange only
+// asks Detrack for the days that may have changed since
+type DayCache interface {
+	// Load returns the cached jobs of a day, or ok=false when the day has to be fetched
+	Load(day time.Time, filters JobFilters) (jobs []Job, ok bool, err error)
+	// Save stores the jobs just fetched for a day
+	Save(day time.Time, filters JobFilters, jobs []Job) error
 }
 
 // NewDetrackClient constructor
@@ -41,80 +141,127 @@ func NewDetrackClient(
 	cfg *config.Config,
 ) *DetrackClient {
 	return &DetrackClient{
-		BaseURL: cfg.BaseURL,
-		APIKey: cfg.APIKey,
-		FetchLimit: cfg.FetchLimit,
-		HTTPClient: &http.Client{Timeout: 60 * time.Second},
-		Logger: logger,
+		BaseURL:          cfg.BaseURL,
+		APIKey:           cfg.APIKey,
+		FetchLimit:       cfg.FetchLimit,
+		HTTPClient:       &http.Client{Timeout: 60 * time.Second},
+		Logger:           logger,
+		FetchConcurrency: cfg.FetchConcurrency,
+		MaxRetries:       cfg.FetchMaxRetries,
+		RetryBaseDelay:   defaultRetryBaseDelay,
+		RetryMaxDelay:    defaultRetryMaxDelay,
+		RetryAfterLimit:  defaultRetryAfterLimit,
 	}
 }
 
-// GetJobs fetches all of the existing jobs on Detrack
-func (c *DetrackClient) GetJobs() ([]Job, error) {
-	c.Logger.Info("Getting all of the existing Jobs on Detrack...")
+// JobFilters narrows a GetJobsInRange query on the Detrack side.
+// Empty fields are not sent.
+type JobFilters struct {
+	Status string // e.g. completed
+	Type   string // Delivery or Collection
+}
 
-	allJobs := []Job{}
-	url := fmt.Sprintf(
-		"%s/dn/jobs?limit=%d",
-		c.BaseURL,
-		c.FetchLimit,
+// GetJobsInRange fetches the jobs dated in [from, to) matching filters.
+// Detrack only filters on a single date, so the range is queried one day
+// at a time, FetchConcurrency days in parallel.
+func (c *DetrackClient) GetJobsInRange(ctx context.Context, from, to time.Time, filters JobFilters) ([]Job, error) {
+	return c.StreamJobsInRange(ctx, from, to, filters).Collect()
+}
+
+// streamDays fetches [from, to) day by day and emits each day's jobs
+func (c *DetrackClient) streamDays(ctx context.Context, from, to time.Time, filters JobFilters, emit Emit) error {
+	c.Logger.Info("Getting Jobs on Detrack in range...",
+		zap.String("from", from.Format(dateLayout)),
+		zap.String("to", to.AddDate(0, 0, -1).Format(dateLayout)),
+		zap.String("status", filters.Status),
+		zap.String("type", filters.Type),
 	)
 
-	for url != "" {
-		// Build a request
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			c.Logger.Error("HTTP request failed", zap.Error(err))
-			return nil, err
-		}
+	firstDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
 
-		req.Header.Set("X-API-KEY", c.APIKey)
-		req.Header.Set("Content-Type", "application/json")
+	var days []time.Time
+	for day := firstDay; day.Before(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
 
-		// Execute request
-		resp, err := c.HTTPClient.Do(req)
-		if err != nil {
-			c.Logger.Error("Failed to read response body", zap.Error(err))
-			return nil, err
+	// Days are fetched in parallel but kept in calendar order. A job can show
+	// up twice when it is edited while the pages are read, so keep the first.
+	// Only the IDs are remembered, not the jobs.
+	seen := make(map[string]bool)
+	total, duplicates := 0, 0
+
+	err := c.fetchDays(ctx, days, filters, func(day time.Time, jobs []Job) error {
+		unique := jobs[:0]
+		for _, job := range jobs {
+			if seen[job.ID] {
+				duplicates++
+				continue
+			}
+			seen[job.ID] = true
+			unique = append(unique, job)
 		}
 
-		// Read response
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		total += len(unique)
+		return emit(unique)
+	})
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			c.Logger.Error("Failed to read response body", zap.Error(err))
-			return nil, err
-		}
+	if duplicates > 0 {
+		c.Logger.Warn("Dropped duplicate jobs", zap.Int("count", duplicates))
+	}
 
-		// // Log the raw JSON response
-		// rawJSON := string(body)
-		// c.Logger.Debug("Raw API response", zap.String("raw", rawJSON))
+	c.Logger.Info("Finished fetching jobs in range", zap.Int("total", total))
+	return nil
+}
 
-		if resp.StatusCode != http.StatusOK {
-			c.Logger.Error("API returned non-200 status",
-				zap.Int("status", resp.StatusCode),
-				zap.String("statusText", resp.Status))
-			break
+// jobsURL builds the /dn/jobs query for a single day
+func (c *DetrackClient) jobsURL(day time.Time, filters JobFilters) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(c.FetchLimit))
+	query.Set("date", day.Format(dateLayout))
+	if filters.Status != "" {
+		query.Set("status", filters.Status)
+	}
+	if filters.Type != "" {
+		query.Set("type", filters.Type)
+	}
+
+	return fmt.Sprintf("%s/dn/jobs?%s", c.BaseURL, query.Encode())
+}
+
+// fetchPages follows the pagination links starting at url and returns every job.
+// It fails rather than returning a partial list when a page cannot be fetched.
+func (c *DetrackClient) fetchPages(ctx context.Context, url string) ([]Job, error) {
+	allJobs := []Job{}
+
+	for url != "" {
+		body, err := c.get(ctx, url)
+		if err != nil {
+			c.Logger.Error("Failed to fetch jobs page", zap.String("url", url), zap.Error(err))
+			return nil, partialPagination(len(allJobs), err)
 		}
 
 		// Response structure
 		var result struct {
-			Data []Job 					`json:"data"`
-			Links map[string]string 	`json:"links"`
+			Data  []Job             `json:"data"`
+			Links map[string]string `json:"links"`
 		}
 
 		if err := json.Unmarshal(body, &result); err != nil {
 			c.Logger.Error("Failed to unmarshal JSON", zap.Error(err))
+			decodeErr := &APIError{Kind: ErrDecode, StatusCode: http.StatusOK, URL: url, BodyExcerpt: excerpt(body), Err: err}
+			return nil, partialPagination(len(allJobs), decodeErr)
 		}
-		
+
 		allJobs = append(allJobs, result.Data...)
-		c.Logger.Info("Retrieved jobs so far", zap.Int("count", len(allJobs)))
+		c.Logger.Debug("Retrieved jobs so far", zap.String("url", url), zap.Int("count", len(allJobs)))
 
-		// Pagination 
+		// Pagination
 		nextLink, ok := result.Links["next"]
 		if ok && nextLink != "" {
-			if nextLink[:4] != "http" {
+			if !strings.HasPrefix(nextLink, "http") {
 				nextLink = c.BaseURL + nextLink
 			}
 			url = nextLink
@@ -124,6 +271,11 @@ func (c *DetrackClient) GetJobs() ([]Job, error) {
 
 	}
 
-	c.Logger.Info("Finished fetching all jobs", zap.Int("total", len(allJobs)))
 	return allJobs, nil
-}
\ No newline at end of file
+}
+
+// partialPagination wraps the error that stopped a paginated fetch
+func partialPagination(jobsFetched int, cause error) *APIError {
+	// The cause already names the page URL
+	return &APIError{Kind: ErrPartialPagination, JobsFetched: jobsFetched, Err: cause}
+}
@@ -0,0 +1,202 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api/detracktest"
+)
+
+var brisbane = time.FixedZone("AEST", 10*60*60)
+
+// feb holds 2026-02-01 on one page and 2026-02-02 on three: the first links
+// to page 2 with a relative URL, page 2 to page 3 with an absolute one
+const feb = "testdata/feb"
+
+func TestGetJobsInRange(t *testing.T) {
+	allJobs := []string{"a1", "b1", "b2", "b3"}
+
+	tests := []struct {
+		name   string
+		faults []detracktest.Fault
+		// setup adjusts the client built by the server, e.g. its timeout
+		setup func(c *api.DetrackClient)
+
+		wantJobs     []string
+		wantErrs     []error // all must match with errors.Is
+		wantFetched  int     // APIError.JobsFetched of a partial pagination
+		wantRequests int     // 0 skips the check
+		minDuration  time.Duration
+	}{
+		{
+			name:         "follows relative and absolute next links",
+			wantJobs:     allJobs,
+			wantRequests: 4,
+		},
+		{
+			name:         "waits the Retry-After of a 429",
+			faults:       []detracktest.Fault{{Query: "page=2", Status: http.StatusTooManyRequests, RetryAfter: "1", Times: 1}},
+			wantJobs:     allJobs,
+			wantRequests: 5,
+			minDuration:  time.Second,
+		},
+		{
+			name:   "fails on a Retry-After over the limit",
+			faults: []detracktest.Fault{{Query: "page=2", Status: http.StatusTooManyRequests, RetryAfter: "3600"}},
+			setup: func(c *api.DetrackClient) {
+				c.RetryAfterLimit = time.Minute
+			},
+			wantErrs:     []error{api.ErrRateLimited, api.ErrPartialPagination},
+			wantFetched:  1,
+			wantRequests: 3,
+		},
+		{
+			name:         "retries 5xx responses",
+			faults:       []detracktest.Fault{{Status: http.StatusServiceUnavailable, Times: 2}},
+			wantJobs:     allJobs,
+			wantRequests: 6,
+		},
+		{
+			name:         "gives up on 5xx after MaxRetries",
+			faults:       []detracktest.Fault{{Query: "page=3", Status: http.StatusBadGateway}},
+			wantErrs:     []error{api.ErrUnavailable, api.ErrPartialPagination},
+			wantFetched:  2,
+			wantRequests: 3 + 4, // three pages, then the first try and 3 retries of page 3
+		},
+		{
+			name:         "fails on a page that is not JSON",
+			faults:       []detracktest.Fault{{Query: "page=2", Status: http.StatusOK, Body: "<html>maintenance</html>"}},
+			wantErrs:     []error{api.ErrDecode, api.ErrPartialPagination},
+			wantFetched:  1,
+			wantRequests: 3,
+		},
+		{
+			name: "does not retry a wrong API key",
+			setup: func(c *api.DetrackClient) {
+				c.APIKey = "wrong"
+			},
+			wantErrs:     []error{api.ErrUnauthorized},
+			wantRequests: 1,
+		},
+		{
+			name:        "waits for slow responses",
+			faults:      []detracktest.Fault{{Delay: 50 * time.Millisecond}},
+			wantJobs:    allJobs,
+			minDuration: 4 * 50 * time.Millisecond,
+		},
+		{
+			name:   "retries a response slower than the client timeout",
+			faults: []detracktest.Fault{{Query: "page=3", Delay: time.Second, Times: 1}},
+			setup: func(c *api.DetrackClient) {
+				c.HTTPClient.Timeout = 100 * time.Millisecond
+			},
+			wantJobs:     allJobs,
+			wantRequests: 5,
+		},
+		{
+			name:   "gives up when every response is too slow",
+			faults: []detracktest.Fault{{Delay: time.Second}},
+			setup: func(c *api.DetrackClient) {
+				c.HTTPClient.Timeout = 20 * time.Millisecond
+				c.MaxRetries = 1
+			},
+			wantErrs:     []error{api.ErrUnavailable, api.ErrPartialPagination},
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, err := detracktest.NewServerFromDir(feb)
+			if err != nil {
+				t.Fatalf("NewServerFromDir: %v", err)
+			}
+			defer srv.Close()
+
+			srv.APIKey = "REDACTED"
+			for _, fault := range tt.faults {
+				srv.Fail(fault)
+			}
+
+			client := srv.Client(zap.NewNop())
+			if tt.setup != nil {
+				tt.setup(client)
+			}
+
+			from := time.Date(2026, 2, 1, 0, 0, 0, 0, brisbane)
+			start := time.Now()
+			jobs, err := client.GetJobsInRange(context.Background(), from, from.AddDate(0, 0, 2), api.JobFilters{Status: "completed"})
+			elapsed := time.Since(start)
+
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("GetJobsInRange: want an error, got %d jobs", len(jobs))
+				}
+				for _, want := range tt.wantErrs {
+					if !errors.Is(err, want) {
+						t.Errorf("error %q does not match %q", err, want)
+					}
+				}
+				if tt.wantFetched > 0 {
+					var apiErr *api.APIError
+					if !errors.As(err, &apiErr) || apiErr.JobsFetched != tt.wantFetched {
+						t.Errorf("JobsFetched of %q, want %d", err, tt.wantFetched)
+					}
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("GetJobsInRange: %v", err)
+				}
+
+				ids := make([]string, len(jobs))
+				for i, job := range jobs {
+					ids[i] = job.ID
+				}
+				if !slices.Equal(ids, tt.wantJobs) {
+					t.Errorf("jobs = %q, want %q", ids, tt.wantJobs)
+				}
+			}
+
+			if requests := srv.Requests(); tt.wantRequests > 0 && len(requests) != tt.wantRequests {
+				t.Errorf("%d requests, want %d:\n%s", len(requests), tt.wantRequests, strings.Join(requests, "\n"))
+			}
+			if elapsed < tt.minDuration {
+				t.Errorf("took %s, want at least %s", elapsed, tt.minDuration)
+			}
+		})
+	}
+}
+
+func TestJobAmounts(t *testing.T) {
+	srv, err := detracktest.NewServerFromDir(feb)
+	if err != nil {
+		t.Fatalf("NewServerFromDir: %v", err)
+	}
+	defer srv.Close()
+
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, brisbane)
+	jobs, err := srv.Client(zap.NewNop()).GetJobsInRange(context.Background(), from, from.AddDate(0, 0, 1), api.JobFilters{Status: "completed"})
+	if err != nil {
+		t.Fatalf("GetJobsInRange: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs, want 1", len(jobs))
+	}
+
+	price, err := jobs[0].Price()
+	if err != nil || price != 450 {
+		t.Errorf("Price() = %d, %v, want 450", price, err)
+	}
+	// A null invoice_amount is blank and counts as 0
+	if invoiced, err := jobs[0].InvoiceAmount(); err != nil || invoiced != 0 {
+		t.Errorf("InvoiceAmount() = %d, %v, want 0", invoiced, err)
+	}
+}
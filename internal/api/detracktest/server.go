@@ -0,0 +1,212 @@
+// Package detracktest replays fixtures saved by record from an httptest
+// server, so DetrackClient can be exercised without network access or
+// credentials.
+package detracktest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api/record"
+)
+
+// Fault makes the server answer matching requests with an error instead of
+// their fixture, e.g. a 429 with Retry-After or a run of 503s, or only slows
+// them down
+type Fault struct {
+	// Query must be contained in the request query, e.g. "page=2"; empty matches any request
+	Query string
+	// Status 0 only delays the request and then serves its fixture
+	Status     int
+	RetryAfter string
+	Body       string
+	Delay      time.Duration
+	// Times is how many requests fail before the fixture is served; 0 fails every time
+	Times int
+}
+
+// Server replays fixtures like Detrack would. Its URL is the client BaseURL.
+//
+// Requests are matched on method, path and query. When several fixtures match
+// the same request they are served in order, the last one repeating. Requests
+// without a fixture get a 404, and a missing or wrong X-API-KEY a 401 when
+// APIKey is set.
+type Server struct {
+	*httptest.Server
+	APIKey string
+
+	mu       sync.Mutex
+	fixtures map[string][]record.Fixture
+	served   map[string]int
+	faults   []*faultState
+	requests []string
+}
+
+type faultState struct {
+	Fault
+	hits int
+}
+
+// NewServer starts a server replaying fixtures
+func NewServer(fixtures []record.Fixture) *Server {
+	s := &Server{
+		fixtures: make(map[string][]record.Fixture),
+		served:   make(map[string]int),
+	}
+	for _, f := range fixtures {
+		s.fixtures[f.Key()] = append(s.fixtures[f.Key()], f)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// NewServerFromDir starts a server replaying the fixtures recorded in dir
+func NewServerFromDir(dir string) (*Server, error) {
+	fixtures, err := record.LoadFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewServer(fixtures), nil
+}
+
+// Fail adds a fault; faults are checked in the order they were added
+func (s *Server) Fail(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultState{Fault: f})
+}
+
+// Requests returns the path and query of every request received so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Client returns a DetrackClient pointed at the server, retrying without
+// waiting so error scenarios run quickly
+func (s *Server) Client(logger *zap.Logger) *api.DetrackClient {
+	return &api.DetrackClient{
+		BaseURL:          s.URL,
+		APIKey:           s.APIKey,
+		FetchLimit:       1000,
+		HTTPClient:       s.Server.Client(),
+		Logger:           logger,
+		FetchConcurrency: 1,
+		MaxRetries:       3,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    10 * time.Millisecond,
+	}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	query := record.CanonicalQuery(r.URL.RawQuery)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.Path+"?"+query)
+	s.mu.Unlock()
+
+	if s.APIKey != "" && r.Header.Get("X-API-KEY") != s.APIKey {
+		writeError(w, http.StatusUnauthorized, `{"message":"Unauthenticated."}`)
+		return
+	}
+
+	s.mu.Lock()
+	fault := s.fault(query)
+	var fixture record.Fixture
+	found := false
+	if fault == nil || fault.Status == 0 {
+		fixture, found = s.next(record.RequestKey(r.Method, r.URL.Path, query))
+	}
+	s.mu.Unlock()
+
+	if fault != nil && !wait(r, fault.Delay) {
+		return
+	}
+
+	if fault != nil && fault.Status != 0 {
+		if fault.RetryAfter != "" {
+			w.Header().Set("Retry-After", fault.RetryAfter)
+		}
+		writeError(w, fault.Status, fault.Body)
+		return
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf(`{"message":"no fixture for %s %s?%s"}`, r.Method, r.URL.Path, query))
+		return
+	}
+
+	if !wait(r, fixture.Delay()) {
+		return
+	}
+
+	for name, values := range fixture.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(fixture.Status)
+	w.Write(fixture.ResponseBody(s.URL))
+}
+
+// fault returns the first active fault matching query; callers hold s.mu
+func (s *Server) fault(query string) *faultState {
+	for _, f := range s.faults {
+		if f.Times > 0 && f.hits >= f.Times {
+			continue
+		}
+		if f.Query != "" && !strings.Contains(query, f.Query) {
+			continue
+		}
+		f.hits++
+		return f
+	}
+	return nil
+}
+
+// next returns the fixture to serve for key; callers hold s.mu
+func (s *Server) next(key string) (record.Fixture, bool) {
+	fixtures := s.fixtures[key]
+	if len(fixtures) == 0 {
+		return record.Fixture{}, false
+	}
+
+	i := min(s.served[key], len(fixtures)-1)
+	s.served[key]++
+	return fixtures[i], true
+}
+
+// wait sleeps for d unless the client gives up first
+func wait(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, body string) {
+	if body == "" {
+		body = fmt.Sprintf(`{"message":"%s"}`, http.StatusText(status))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
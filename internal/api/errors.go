@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error kinds returned by DetrackClient, usable with errors.Is
+var (
+	ErrUnauthorized      = errors.New("detrack rejected the API key")
+	ErrRateLimited       = errors.New("detrack rate limit still exceeded after retries")
+	ErrUnavailable       = errors.New("detrack unavailable after retries")
+	ErrUnexpectedStatus  = errors.New("detrack returned an unexpected status")
+	ErrDecode            = errors.New("detrack response could not be decoded")
+	ErrPartialPagination = errors.New("detrack pagination did not finish")
+)
+
+// bodyExcerptLength caps how much of a response body is kept on an APIError
+const bodyExcerptLength = 300
+
+// APIError describes a failed Detrack request. Kind is one of the Err*
+// values above; Err is the underlying cause, if any.
+type APIError struct {
+	Kind        error
+	StatusCode  int    // 0 when no response was received
+	URL         string // the API key is sent as a header, so URLs are safe to log
+	BodyExcerpt string
+	JobsFetched int // jobs fetched before pagination stopped, ErrPartialPagination only
+	Err         error
+}
+
+func (e *APIError) Error() string {
+	var msg strings.Builder
+	msg.WriteString(e.Kind.Error())
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&msg, " (%d %s)", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Kind == ErrPartialPagination {
+		fmt.Fprintf(&msg, " after %d jobs", e.JobsFetched)
+	}
+	if e.URL != "" {
+		fmt.Fprintf(&msg, " for %s", e.URL)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&msg, ": %v", e.Err)
+	}
+	if e.BodyExcerpt != "" {
+		fmt.Fprintf(&msg, ": %q", e.BodyExcerpt)
+	}
+	return msg.String()
+}
+
+// Unwrap lets errors.Is match both the kind and the cause
+func (e *APIError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// statusError classifies a non-200 response
+func statusError(url string, resp *response, cause error) *APIError {
+	apiErr := &APIError{Kind: ErrUnavailable, URL: url, Err: cause}
+	if resp == nil {
+		return apiErr
+	}
+
+	apiErr.StatusCode = resp.status
+	apiErr.BodyExcerpt = excerpt(resp.body)
+
+	switch {
+	case resp.status == http.StatusUnauthorized || resp.status == http.StatusForbidden:
+		apiErr.Kind = ErrUnauthorized
+	case resp.status == http.StatusTooManyRequests:
+		apiErr.Kind = ErrRateLimited
+	case resp.status >= 500:
+		apiErr.Kind = ErrUnavailable
+	default:
+		apiErr.Kind = ErrUnexpectedStatus
+	}
+
+	return apiErr
+}
+
+// excerpt keeps the start of a body for error messages, cut on a rune boundary
+func excerpt(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if len(text) <= bodyExcerptLength {
+		return text
+	}
+
+	cut := bodyExcerptLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
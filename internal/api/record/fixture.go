@@ -0,0 +1,99 @@
+// Package record saves Detrack API responses as fixture files, which
+// detracktest replays so DetrackClient can be exercised without network
+// access or credentials.
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BaseURLPlaceholder stands in for the recorded base URL in fixture bodies,
+// so absolute pagination links point at whichever server replays them
+const BaseURLPlaceholder = "{{BASE_URL}}"
+
+// Fixture is one recorded response. Path is relative to the client BaseURL
+// (e.g. /dn/jobs) and Query is canonical, with its keys sorted.
+type Fixture struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	// Body holds a JSON response as is; anything else goes in BodyText
+	Body     json.RawMessage `json:"body,omitempty"`
+	BodyText string          `json:"body_text,omitempty"`
+	// Delay makes the replay wait before answering, e.g. to hit client timeouts
+	DelayMS int `json:"delay_ms,omitempty"`
+}
+
+// Key identifies the request a fixture answers
+func (f Fixture) Key() string {
+	return RequestKey(f.Method, f.Path, f.Query)
+}
+
+// Delay is how long the replay waits before answering
+func (f Fixture) Delay() time.Duration {
+	return time.Duration(f.DelayMS) * time.Millisecond
+}
+
+// ResponseBody returns the response body with the base URL placeholder replaced
+func (f Fixture) ResponseBody(baseURL string) []byte {
+	body := f.BodyText
+	if len(f.Body) > 0 {
+		body = string(f.Body)
+	}
+	return []byte(strings.ReplaceAll(body, BaseURLPlaceholder, baseURL))
+}
+
+// LoadFixtures reads every *.json fixture in dir, in file name order
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		if f.Method == "" {
+			f.Method = http.MethodGet
+		}
+		f.Query = CanonicalQuery(f.Query)
+
+		fixtures = append(fixtures, f)
+	}
+
+	return fixtures, nil
+}
+
+// RequestKey identifies a request by method, path and canonical query
+func RequestKey(method, path, query string) string {
+	return method + " " + path + "?" + query
+}
+
+// CanonicalQuery sorts the query keys so fixtures match however the client
+// ordered its parameters
+func CanonicalQuery(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	return values.Encode()
+}
@@ -0,0 +1,194 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the API key and the scrubbed fields in a recording
+const Redacted = "REDACTED"
+
+// unsafeName matches the characters not kept in fixture file names
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Recorder is an http.RoundTripper that saves every response it passes on
+// as a fixture in Dir. Request headers are never saved, the API key is
+// replaced with Redacted anywhere it appears in a URL or body, and so are the
+// values of the Scrub fields in JSON bodies.
+type Recorder struct {
+	Dir     string
+	BaseURL string // client BaseURL, stored as BaseURLPlaceholder
+	APIKey  string
+	// Scrub names the JSON fields whose values are personal data, at any depth
+	Scrub []string
+	Next  http.RoundTripper // nil uses http.DefaultTransport
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder creates dir and records the responses of next into it, with the
+// scrub fields redacted
+func NewRecorder(dir, baseURL, apiKey string, scrub []string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture dir: %w", err)
+	}
+
+	return &Recorder{
+		Dir:     dir,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Scrub:   scrub,
+		Next:    next,
+	}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := r.save(req, resp, body); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// save writes the response as the next numbered fixture, so repeated
+// requests (e.g. retries) replay in the order they were recorded
+func (r *Recorder) save(req *http.Request, resp *http.Response, body []byte) error {
+	path := strings.TrimPrefix(req.URL.Path, pathOf(r.BaseURL))
+
+	f := Fixture{
+		Method: req.Method,
+		Path:   path,
+		Query:  r.redact(CanonicalQuery(req.URL.RawQuery)),
+		Status: resp.StatusCode,
+		Header: http.Header{},
+	}
+
+	// Only keep the headers the client reads
+	for _, name := range []string{"Content-Type", "Retry-After"} {
+		if value := resp.Header.Get(name); value != "" {
+			f.Header.Set(name, value)
+		}
+	}
+
+	text := r.redact(string(body))
+	if r.BaseURL != "" {
+		text = strings.ReplaceAll(text, r.BaseURL, BaseURLPlaceholder)
+	}
+	if json.Valid([]byte(text)) {
+		scrubbed, err := r.scrub([]byte(text))
+		if err != nil {
+			return fmt.Errorf("failed to scrub fixture: %w", err)
+		}
+		f.Body = scrubbed
+	} else {
+		f.BodyText = text
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	r.mu.Lock()
+	r.seq++
+	name := fmt.Sprintf("%04d_%s_%s.json", r.seq, strings.Trim(unsafeName.ReplaceAllString(path, "_"), "_"), unsafeName.ReplaceAllString(f.Query, "_"))
+	r.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(r.Dir, name), data.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) redact(s string) string {
+	if r.APIKey == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, r.APIKey, Redacted)
+}
+
+// scrub replaces the values of the Scrub fields with Redacted. Blank and
+// null values are kept, so replays still see which jobs had none.
+func (r *Recorder) scrub(body []byte) (json.RawMessage, error) {
+	if len(r.Scrub) == 0 {
+		return body, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // keep amounts and IDs as they were sent
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]bool{}
+	for _, field := range r.Scrub {
+		fields[field] = true
+	}
+	scrubValue(value, fields)
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(data.Bytes()), nil
+}
+
+func scrubValue(value any, fields map[string]bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if fields[key] && item != nil && item != "" {
+				v[key] = Redacted
+				continue
+			}
+			scrubValue(item, fields)
+		}
+	case []any:
+		for _, item := range v {
+			scrubValue(item, fields)
+		}
+	}
+}
+
+// pathOf returns the path part of a base URL, e.g. /api/v2
+func pathOf(baseURL string) string {
+	if i := strings.Index(baseURL, "://"); i >= 0 {
+		baseURL = baseURL[i+3:]
+	}
+	if i := strings.Index(baseURL, "/"); i >= 0 {
+		return baseURL[i:]
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package record_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api/record"
+)
+
+// jobsPage is a Detrack response holding personal data in the scrubbed fields
+const jobsPage = `{
+  "data": [
+    {"id": "j1", "do_number": "DO-1", "run_number": "NORTH", "job_price": "4.50",
+     "address": "1 Main St, Brisbane QLD 4000", "deliver_to_collect_from": "John Citizen",
+     "received_by_sent_by": "Jane Citizen", "items": [{"sku": "A", "address": "2 Side St"}]},
+    {"id": "j2", "do_number": "DO-2", "run_number": "SOUTH", "job_price": 7,
+     "address": "", "deliver_to_collect_from": null, "received_by_sent_by": "Bob Smith"}
+  ],
+  "links": {"next": "%s/dn/jobs?page=2&api_key=secret-key"}
+}`
+
+var personalData = []string{"1 Main St", "2 Side St", "John Citizen", "Jane Citizen", "Bob Smith", "secret-key"}
+
+func TestRecorderScrubs(t *testing.T) {
+	tests := []struct {
+		name  string
+		scrub []string
+
+		wantGone []string // not in any fixture
+		wantKept []string // still in the fixture
+	}{
+		{
+			name:     "default fields",
+			scrub:    []string{"address", "deliver_to_collect_from", "received_by_sent_by"},
+			wantGone: personalData,
+			wantKept: []string{`"do_number": "DO-1"`, `"run_number": "SOUTH"`, `"job_price": 7`, `"address": ""`, `"deliver_to_collect_from": null`},
+		},
+		{
+			name:     "configured fields",
+			scrub:    []string{"received_by_sent_by", "do_number"},
+			wantGone: []string{"Jane Citizen", "Bob Smith", "DO-1", "secret-key"},
+			wantKept: []string{"1 Main St", "John Citizen"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, strings.Replace(jobsPage, "%s", srv.URL, 1))
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			recorder, err := record.NewRecorder(dir, srv.URL, "secret-key", tt.scrub, nil)
+			if err != nil {
+				t.Fatalf("NewRecorder: %v", err)
+			}
+
+			client := &http.Client{Transport: recorder}
+			resp, err := client.Get(srv.URL + "/dn/jobs?date=2026-02-01&api_key=secret-key")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			// The client still gets the response as sent
+			if !strings.Contains(string(body), "John Citizen") {
+				t.Errorf("response passed on was scrubbed too: %s", body)
+			}
+
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("recorded %q, %v, want one fixture", files, err)
+			}
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fixture record.Fixture
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("fixture is not JSON: %v", err)
+			}
+			for _, gone := range tt.wantGone {
+				if strings.Contains(string(data), gone) || strings.Contains(files[0], gone) {
+					t.Errorf("fixture still holds %q:\n%s", gone, data)
+				}
+			}
+			for _, kept := range tt.wantKept {
+				if !strings.Contains(string(data), kept) {
+					t.Errorf("fixture lost %q:\n%s", kept, data)
+				}
+			}
+			if !strings.Contains(string(data), record.BaseURLPlaceholder) {
+				t.Errorf("fixture does not replace the base URL with %s:\n%s", record.BaseURLPlaceholder, data)
+			}
+		})
+	}
+}
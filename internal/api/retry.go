@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Retry defaults used by NewDetrackClient
+const (
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 60 * time.Second
+	// Detrack asks for up to a few minutes; longer would hold up the run for too long
+	defaultRetryAfterLimit = 10 * time.Minute
+)
+
+// response is a fully read HTTP response
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// get performs a GET, retrying timeouts, transport errors, 5xx and 429
+// responses with exponential backoff, and returns the body of a 200
+func (c *DetrackClient) get(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, url)
+
+		if err == nil && resp.status == http.StatusOK {
+			return resp.body, nil
+		}
+
+		// The run was cancelled or timed out as a whole: never retry
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		apiErr := statusError(url, resp, err)
+
+		if !isRetryable(resp) {
+			return nil, apiErr
+		}
+
+		if attempt >= c.MaxRetries {
+			return nil, apiErr
+		}
+
+		// Wait as long as a 429 asks: retrying sooner only uses up attempts on more 429s
+		wait := c.backoff(attempt)
+		if resp != nil && resp.status == http.StatusTooManyRequests {
+			if retryAfter, ok := parseRetryAfter(resp.header.Get("Retry-After")); ok {
+				if c.RetryAfterLimit > 0 && retryAfter > c.RetryAfterLimit {
+					apiErr.Err = fmt.Errorf("Retry-After of %s is longer than the %s limit", retryAfter, c.RetryAfterLimit)
+					return nil, apiErr
+				}
+				wait = retryAfter
+			}
+		}
+
+		c.Logger.Warn("Detrack request failed, retrying",
+			zap.String("url", url),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("wait", wait),
+			zap.Error(apiErr),
+		)
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// do sends one request and reads the whole body. A nil response means the
+// request never got one (transport error or timeout).
+func (c *DetrackClient) do(ctx context.Context, url string) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-API-KEY", c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// isRetryable retries requests that got no response, rate limits and server errors
+func isRetryable(resp *response) bool {
+	if resp == nil {
+		return true
+	}
+	return resp.status == http.StatusTooManyRequests || resp.status >= 500
+}
+
+// backoff doubles the delay per attempt, capped, with jitter over the upper half
+// so parallel clients do not retry in lockstep
+func (c *DetrackClient) backoff(attempt int) time.Duration {
+	delay := c.RetryBaseDelay << min(attempt, 16)
+	if delay <= 0 || delay > c.RetryMaxDelay {
+		delay = c.RetryMaxDelay
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return errors.Join(errors.New("cancelled while waiting to retry"), ctx.Err())
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// JobStream hands out jobs one page at a time, like sql.Rows:
+//
+//	for stream.Next() {
+//		job := stream.Job()
+//	}
+//	if err := stream.Err(); err != nil { ... }
+//
+// Only the page being read and the pages the producer is working on are in
+// memory, however many jobs the stream yields in total.
+type JobStream struct {
+	pages  chan []Job
+	cancel context.CancelFunc
+	err    error // set by the producer before pages is closed
+
+	page []Job
+	pos  int
+	cur  Job
+}
+
+// Emit is called by a JobStream producer for each page of jobs. It fails once
+// the stream is closed or its context is done.
+type Emit func(jobs []Job) error
+
+// NewJobStream runs produce in the background, passing its pages to the
+// reader. The error produce returns is reported by Err.
+func NewJobStream(ctx context.Context, produce func(ctx context.Context, emit Emit) error) *JobStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &JobStream{
+		pages:  make(chan []Job),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(s.pages)
+		s.err = produce(ctx, func(jobs []Job) error {
+			select {
+			case s.pages <- jobs:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	return s
+}
+
+// Next advances to the next job, waiting for the next page if needed.
+// It returns false at the end of the stream or on error.
+func (s *JobStream) Next() bool {
+	for s.pos >= len(s.page) {
+		page, ok := <-s.pages
+		if !ok {
+			return false
+		}
+		s.page, s.pos = page, 0
+	}
+
+	s.cur = s.page[s.pos]
+	s.pos++
+	return true
+}
+
+// Job returns the current job
+func (s *JobStream) Job() Job {
+	return s.cur
+}
+
+// Err returns the error that ended the stream, once Next has returned false
+func (s *JobStream) Err() error {
+	return s.err
+}
+
+// Close stops the producer. It is safe to call after the stream ended.
+func (s *JobStream) Close() {
+	s.cancel()
+	for range s.pages {
+		// Drain so the producer can exit
+	}
+}
+
+// Collect reads the rest of the stream into a slice
+func (s *JobStream) Collect() ([]Job, error) {
+	defer s.Close()
+
+	jobs := []Job{}
+	for s.Next() {
+		jobs = append(jobs, s.Job())
+	}
+	return jobs, s.Err()
+}
+
+// StreamJobsInRange is GetJobsInRange as a stream: each day is handed over
+// as soon as it and every day before it has been fetched.
+func (c *DetrackClient) StreamJobsInRange(ctx context.Context, from, to time.Time, filters JobFilters) *JobStream {
+	return NewJobStream(ctx, func(ctx context.Context, emit Emit) error {
+		return c.streamDays(ctx, from, to, filters, emit)
+	})
+}
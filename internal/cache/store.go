@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+)
+
+// ErrNotCached is returned in offline mode for a day that was never synced
+var ErrNotCached = errors.New("day not in the local job cache")
+
+const (
+	dateLayout   = "2006-01-02"
+	manifestFile = "manifest.json"
+
+	// version is bumped whenever api.Job gains fields, so days cached
+	// without them are fetched again
+	version = 2
+)
+
+// Store is a local copy of the jobs fetched from Detrack. Each day is a JSON
+// lines file of jobs keyed by ID, and manifest.json records when every day was
+// last synced.
+//
+// Detrack cannot list the jobs changed since a given time, so the watermark is
+// kept per day: a day synced at least settleDays after it ended is assumed
+// final and read from disk, more recent days are fetched again. Edits made
+// after that are picked up when the copy is refreshDays old, or at once in
+// refresh mode.
+type Store struct {
+	dir         string
+	logger      *zap.Logger
+	mode        string // config.CacheSync, config.CacheRefresh or config.CacheOffline
+	settleDays  int
+	refreshDays int // 0 keeps settled days for good
+	now         func() time.Time
+
+	mu       sync.Mutex // days are saved from several fetch workers
+	manifest manifest
+}
+
+type manifest struct {
+	Version  int                `json:"version"`
+	LastSync time.Time          `json:"last_sync"`
+	Days     map[string]daySync `json:"days"` // keyed by filters/date
+}
+
+type daySync struct {
+	SyncedAt time.Time `json:"synced_at"`
+	Jobs     int       `json:"jobs"`
+}
+
+// Open loads the store under dir, creating it when missing. In offline mode
+// every day is read from disk and days never synced fail with ErrNotCached.
+// An unreadable manifest starts a new cache, except in offline mode.
+func Open(logger *zap.Logger, dir, mode string, settleDays, refreshDays int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	s := &Store{
+		dir:         dir,
+		logger:      logger,
+		mode:        mode,
+		settleDays:  settleDays,
+		refreshDays: refreshDays,
+		now:         time.Now,
+		manifest:    manifest{Version: version, Days: make(map[string]daySync)},
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logger.Info("Starting a new job cache", zap.String("dir", dir))
+	case err != nil:
+		return nil, fmt.Errorf("failed to read cache manifest: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.manifest); err != nil {
+			if s.offline() {
+				return nil, fmt.Errorf("failed to parse cache manifest: %w", err)
+			}
+			// Every day is fetched again and the manifest rewritten on the first save
+			logger.Warn("Job cache manifest is corrupt, starting over", zap.Error(err))
+			s.manifest = manifest{Version: version}
+		} else if s.manifest.Version != version {
+			logger.Warn("Job cache was written by an older version, starting over",
+				zap.Int("version", s.manifest.Version),
+			)
+			s.manifest = manifest{Version: version}
+		}
+		if s.manifest.Days == nil {
+			s.manifest.Days = make(map[string]daySync)
+		}
+		logger.Info("Opened job cache",
+			zap.String("dir", dir),
+			zap.Time("lastSync", s.manifest.LastSync),
+			zap.Int("days", len(s.manifest.Days)),
+		)
+	}
+
+	return s, nil
+}
+
+// Load returns the cached jobs of a day when the day has settled and its copy
+// is recent enough, or always in offline mode. Refresh mode never reads the
+// cache. A day file that cannot be read is fetched again, except in offline
+// mode.
+func (s *Store) Load(day time.Time, filters api.JobFilters) ([]api.Job, bool, error) {
+	if s.mode == config.CacheRefresh {
+		return nil, false, nil
+	}
+
+	key := dayKey(day, filters)
+
+	s.mu.Lock()
+	synced, ok := s.manifest.Days[key]
+	s.mu.Unlock()
+
+	if !ok {
+		if s.offline() {
+			return nil, false, fmt.Errorf("%s: %w", key, ErrNotCached)
+		}
+		return nil, false, nil
+	}
+
+	if !s.offline() && (!s.settled(day, synced.SyncedAt) || s.stale(synced.SyncedAt)) {
+		return nil, false, nil
+	}
+
+	jobs, err := readJobs(s.path(key))
+	if err != nil {
+		if s.offline() {
+			return nil, false, fmt.Errorf("failed to read cached jobs for %s: %w", key, err)
+		}
+		s.logger.Warn("Cached jobs cannot be read, fetching the day again",
+			zap.String("day", key),
+			zap.Error(err),
+		)
+		return nil, false, nil
+	}
+
+	return jobs, true, nil
+}
+
+// Save replaces the jobs of a day and records it as synced now
+func (s *Store) Save(day time.Time, filters api.JobFilters, jobs []api.Job) error {
+	if s.offline() {
+		return nil
+	}
+
+	key := dayKey(day, filters)
+	path := s.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	count, err := writeJobs(path, jobs)
+	if err != nil {
+		return fmt.Errorf("failed to cache jobs for %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.manifest.Days[key] = daySync{SyncedAt: now, Jobs: count}
+	if now.After(s.manifest.LastSync) {
+		s.manifest.LastSync = now
+	}
+
+	return s.writeManifest()
+}
+
+// settled reports whether a day synced at syncedAt can no longer change
+func (s *Store) settled(day, syncedAt time.Time) bool {
+	end := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+	return !syncedAt.Before(end.AddDate(0, 0, s.settleDays))
+}
+
+// stale reports whether a settled day's copy is old enough to fetch again
+func (s *Store) stale(syncedAt time.Time) bool {
+	return s.refreshDays > 0 && !s.now().Before(syncedAt.AddDate(0, 0, s.refreshDays))
+}
+
+func (s *Store) offline() bool {
+	return s.mode == config.CacheOffline
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key)+".jsonl")
+}
+
+// writeManifest replaces manifest.json atomically; callers hold s.mu
+func (s *Store) writeManifest() error {
+	data, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache manifest: %w", err)
+	}
+
+	path := filepath.Join(s.dir, manifestFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cache manifest: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// dayKey names a day's file, e.g. status-completed/2026-02-01
+func dayKey(day time.Time, filters api.JobFilters) string {
+	parts := []string{}
+	if filters.Status != "" {
+		parts = append(parts, "status-"+filters.Status)
+	}
+	if filters.Type != "" {
+		parts = append(parts, "type-"+filters.Type)
+	}
+
+	group := "all"
+	if len(parts) > 0 {
+		group = strings.ToLower(strings.Join(parts, "_"))
+	}
+
+	return group + "/" + day.Format(dateLayout)
+}
+
+// writeJobs writes one job per line, keeping the last copy of each ID, and
+// returns the number of jobs written
+func writeJobs(path string, jobs []api.Job) (int, error) {
+	index := make(map[string]int, len(jobs))
+	unique := make([]api.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if i, ok := index[job.ID]; ok {
+			unique[i] = job
+			continue
+		}
+		index[job.ID] = len(unique)
+		unique = append(unique, job)
+	}
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return 0, err
+	}
+
+	buf := bufio.NewWriter(file)
+	encoder := json.NewEncoder(buf)
+	for _, job := range unique {
+		if err := encoder.Encode(job); err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+
+	return len(unique), os.Rename(path+".tmp", path)
+}
+
+func readJobs(path string) ([]api.Job, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	jobs := []api.Job{}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var job api.Job
+		if err := decoder.Decode(&job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
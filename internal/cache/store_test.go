@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+)
+
+var (
+	brisbane  = time.FixedZone("AEST", 10*60*60)
+	completed = api.JobFilters{Status: "completed"}
+	// day is cached in every test; it ends at midnight starting 2026-02-11
+	day = time.Date(2026, 2, 10, 0, 0, 0, 0, brisbane)
+)
+
+// open opens the store in dir with settle days 7 and refresh days 30,
+// pretending the time is now
+func open(t *testing.T, dir, mode string, now time.Time) *Store {
+	t.Helper()
+	s, err := Open(zap.NewNop(), dir, mode, 7, 30)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func ids(jobs []api.Job) []string {
+	out := make([]string, len(jobs))
+	for i, job := range jobs {
+		out[i] = job.ID
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	afterDay := func(days int) time.Time { return day.AddDate(0, 0, 1+days) }
+
+	tests := []struct {
+		name     string
+		syncedAt time.Time // when the day was saved
+		mode     string
+		now      time.Time // when it is loaded
+
+		wantHit bool
+	}{
+		{name: "synced before it settled", syncedAt: afterDay(3), mode: config.CacheSync, now: afterDay(20), wantHit: false},
+		{name: "synced once settled", syncedAt: afterDay(7), mode: config.CacheSync, now: afterDay(20), wantHit: true},
+		{name: "settled copy older than the refresh days", syncedAt: afterDay(7), mode: config.CacheSync, now: afterDay(7 + 30), wantHit: false},
+		{name: "refresh mode never reads the cache", syncedAt: afterDay(7), mode: config.CacheRefresh, now: afterDay(8), wantHit: false},
+		{name: "offline reads days that have not settled", syncedAt: afterDay(1), mode: config.CacheOffline, now: afterDay(2), wantHit: true},
+		{name: "offline reads stale copies", syncedAt: afterDay(7), mode: config.CacheOffline, now: afterDay(100), wantHit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := open(t, dir, config.CacheSync, tt.syncedAt).Save(day, completed, []api.Job{{ID: "a"}, {ID: "b"}}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			jobs, hit, err := open(t, dir, tt.mode, tt.now).Load(day, completed)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if hit != tt.wantHit {
+				t.Fatalf("Load hit = %v, want %v", hit, tt.wantHit)
+			}
+			if hit && !slices.Equal(ids(jobs), []string{"a", "b"}) {
+				t.Errorf("Load = %q, want [a b]", ids(jobs))
+			}
+		})
+	}
+}
+
+func TestLoadMissingDay(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, hit, err := open(t, dir, config.CacheSync, day).Load(day, completed); hit || err != nil {
+		t.Errorf("sync Load of a missing day = %v, %v, want a miss", hit, err)
+	}
+	if _, _, err := open(t, dir, config.CacheOffline, day).Load(day, completed); !errors.Is(err, ErrNotCached) {
+		t.Errorf("offline Load of a missing day = %v, want ErrNotCached", err)
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	syncedAt := day.AddDate(0, 0, 10)
+
+	s := open(t, dir, config.CacheSync, syncedAt)
+	// Duplicate IDs keep the last copy
+	jobs := []api.Job{{ID: "a", JobPrice: "1.00"}, {ID: "b"}, {ID: "a", JobPrice: "2.00"}}
+	if err := s.Save(day, completed, jobs); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(day, api.JobFilters{}, nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reopened := open(t, dir, config.CacheSync, syncedAt)
+	if !reopened.manifest.LastSync.Equal(syncedAt) {
+		t.Errorf("LastSync = %s, want %s", reopened.manifest.LastSync, syncedAt)
+	}
+	want := map[string]int{"status-completed/2026-02-10": 2, "all/2026-02-10": 0}
+	if len(reopened.manifest.Days) != len(want) {
+		t.Errorf("manifest days = %v, want %v", reopened.manifest.Days, want)
+	}
+	for key, count := range want {
+		synced, ok := reopened.manifest.Days[key]
+		if !ok || synced.Jobs != count || !synced.SyncedAt.Equal(syncedAt) {
+			t.Errorf("manifest day %s = %+v, want %d jobs synced at %s", key, synced, count, syncedAt)
+		}
+	}
+
+	cached, hit, err := reopened.Load(day, completed)
+	if err != nil || !hit {
+		t.Fatalf("Load = %v, %v, want a hit", hit, err)
+	}
+	if !slices.Equal(ids(cached), []string{"a", "b"}) || cached[0].JobPrice != "2.00" {
+		t.Errorf("Load = %+v, want a (2.00) and b", cached)
+	}
+}
+
+func TestOlderVersionStartsOver(t *testing.T) {
+	dir := t.TempDir()
+	if err := open(t, dir, config.CacheSync, day.AddDate(0, 0, 10)).Save(day, completed, []api.Job{{ID: "a"}}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	path := filepath.Join(dir, manifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	m.Version = version - 1
+	data, _ = json.Marshal(m)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, hit, err := open(t, dir, config.CacheSync, day.AddDate(0, 0, 11)).Load(day, completed); hit || err != nil {
+		t.Errorf("Load after a version change = %v, %v, want a miss", hit, err)
+	}
+}
+
+func TestCorruptFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt string // file to overwrite, relative to the cache dir
+		mode    string
+
+		wantOpenErr bool
+		wantLoadErr bool
+	}{
+		{name: "manifest in sync mode starts over", corrupt: manifestFile, mode: config.CacheSync},
+		{name: "manifest in offline mode fails", corrupt: manifestFile, mode: config.CacheOffline, wantOpenErr: true},
+		{name: "day file in sync mode is fetched again", corrupt: "status-completed/2026-02-10.jsonl", mode: config.CacheSync},
+		{name: "day file in offline mode fails", corrupt: "status-completed/2026-02-10.jsonl", mode: config.CacheOffline, wantLoadErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := open(t, dir, config.CacheSync, day.AddDate(0, 0, 10)).Save(day, completed, []api.Job{{ID: "a"}}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(tt.corrupt)), []byte(`{"id": "a", "sta`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := Open(zap.NewNop(), dir, tt.mode, 7, 30)
+			if tt.wantOpenErr {
+				if err == nil {
+					t.Fatal("Open: want an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			s.now = func() time.Time { return day.AddDate(0, 0, 11) }
+
+			jobs, hit, err := s.Load(day, completed)
+			if tt.wantLoadErr {
+				if err == nil {
+					t.Fatalf("Load: want an error, got %d jobs", len(jobs))
+				}
+				return
+			}
+			if err != nil || hit {
+				t.Fatalf("Load = %v, %v, want a miss so the day is fetched again", hit, err)
+			}
+
+			// Saving the fetched day repairs the cache
+			if err := s.Save(day, completed, []api.Job{{ID: "a"}}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if _, hit, err := open(t, dir, config.CacheSync, day.AddDate(0, 0, 12)).Load(day, completed); !hit || err != nil {
+				t.Errorf("Load after repair = %v, %v, want a hit", hit, err)
+			}
+		})
+	}
+}
@@ -4,19 +4,98 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// Reconciliation modes for RECONCILE_MODE
+const (
+	ReconcileFail = "fail" // abort the run without sending the report
+	ReconcileFlag = "flag" // send the report with a warning in the email
+)
+
+// Policies for PRICE_POLICY, applied to jobs whose job_price cannot be parsed
+const (
+	PriceReject     = "reject"     // fail the run
+	PriceZero       = "zero"       // count the job with a price of 0
+	PriceQuarantine = "quarantine" // leave the job out of the report and list it on the Quarantine sheet
+)
+
+// Reports for REPORT_MODE
+const (
+	ReportRuns    = "runs"    // the per-run report
+	ReportDrivers = "drivers" // the per-driver report only
+	ReportAll     = "all"     // both
+)
+
+// Earlier periods for COMPARE, comma separated
+const (
+	CompareOff      = "off"       // no comparison
+	ComparePrevious = "previous"  // the previous week, month or quarter
+	CompareLastYear = "last_year" // the same period a year earlier
+)
+
+// Modes for CACHE_MODE, the local job cache under CACHE_DIR
+const (
+	CacheOff     = "off"     // always fetch every day from Detrack
+	CacheSync    = "sync"    // read settled days from the cache, fetch and store the rest
+	CacheRefresh = "refresh" // fetch every day from Detrack and replace the cached copy
+	CacheOffline = "offline" // only read the cache; Detrack is never called
+)
+
 type Config struct {
-	BaseURL        string
-	APIKey         string
-	FetchLimit     int
-	SMTPHost       string
-	SMTPPort       string
-	EmailSender    string
-	EmailPassword  string
-	EmailReceivers string
+	BaseURL          string
+	APIKey           string
+	FetchLimit       int
+	FetchMaxRetries  int
+	FetchConcurrency int
+	SMTPHost         string
+	SMTPPort         string
+	EmailSender      string
+	EmailPassword    string
+	EmailReceivers   string
+	// AttachmentZipAbove zips report files larger than this many bytes; 0 never zips
+	AttachmentZipAbove int64
+	// AttachmentMaxBytes leaves out larger files, linking to them under AttachmentLinkBase; 0 has no limit
+	AttachmentMaxBytes int64
+	AttachmentLinkBase string
+	// AttachmentPublishDir is where linked files are copied to, served under AttachmentLinkBase
+	AttachmentPublishDir string
+	// AttachmentUploadURL is where linked files are PUT to instead, with AttachmentUploadToken as the bearer token
+	AttachmentUploadURL   string
+	AttachmentUploadToken string
+	// NotifyChannels is an optional JSON file of channels to send the report on; empty emails EMAIL_RECEIVERS
+	NotifyChannels string
+	// RecipientGroups is an optional JSON file of receivers that each get their own filtered report
+	RecipientGroups string
+	ReconcileMode   string
+	ReportMode      string
+	// Compare lists the earlier periods shown next to the Report rows; empty when off
+	Compare []string
+	// CompareMoverPercent is the change at which a run is listed in the email
+	CompareMoverPercent float64
+	PricePolicy         string
+	// NormalizerRules is an optional JSON file of run number rules; empty uses the built-in defaults
+	NormalizerRules string
+	OutputFormats   []string
+	// JobsColumns picks the Jobs sheet columns; empty uses the default set
+	JobsColumns []string
+	// Pivots is an optional JSON file of extra summary sheets grouped by any dimensions
+	Pivots    string
+	CacheMode string
+	CacheDir  string
+	// CacheSettleDays is how long after a day ends its jobs are assumed final
+	CacheSettleDays int
+	// CacheRefreshDays is how old a settled day's copy can get before it is
+	// fetched again, to pick up late edits in Detrack; 0 never fetches it again
+	CacheRefreshDays int
+	// JobsFile is an optional .json/.jsonl snapshot to report from instead of Detrack
+	JobsFile string
+	// RecordDir, when set, saves every Detrack response as a replayable fixture
+	RecordDir string
+	// RecordScrub lists the job fields redacted in the fixtures, e.g. names and addresses
+	RecordScrub []string
 }
 
 func LoadConfig() (*Config, error) {
@@ -30,32 +109,147 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("Cannot convert FETCH_LIMIT to Int")
 	}
 
+	fetchMaxRetries, err := strconv.Atoi(getEnv("FETCH_MAX_RETRIES", "5"))
+	if err != nil || fetchMaxRetries < 0 {
+		return nil, errors.New("Cannot convert FETCH_MAX_RETRIES to a non-negative Int")
+	}
+
+	fetchConcurrency, err := strconv.Atoi(getEnv("FETCH_CONCURRENCY", "4"))
+	if err != nil || fetchConcurrency < 1 {
+		return nil, errors.New("Cannot convert FETCH_CONCURRENCY to a positive Int")
+	}
+
+	cacheSettleDays, err := strconv.Atoi(getEnv("CACHE_SETTLE_DAYS", "7"))
+	if err != nil || cacheSettleDays < 0 {
+		return nil, errors.New("Cannot convert CACHE_SETTLE_DAYS to a non-negative Int")
+	}
+
+	cacheRefreshDays, err := strconv.Atoi(getEnv("CACHE_REFRESH_DAYS", "30"))
+	if err != nil || cacheRefreshDays < 0 {
+		return nil, errors.New("Cannot convert CACHE_REFRESH_DAYS to a non-negative Int")
+	}
+
+	compareMoverPercent, err := strconv.ParseFloat(getEnv("COMPARE_MOVER_PCT", "20"), 64)
+	if err != nil || compareMoverPercent < 0 {
+		return nil, errors.New("Cannot convert COMPARE_MOVER_PCT to a non-negative number")
+	}
+
+	attachmentZipAbove, err := strconv.ParseInt(getEnv("ATTACHMENT_ZIP_ABOVE", "0"), 10, 64)
+	if err != nil || attachmentZipAbove < 0 {
+		return nil, errors.New("Cannot convert ATTACHMENT_ZIP_ABOVE to a non-negative number of bytes")
+	}
+
+	// Gmail rejects messages over 25 MB, and base64 makes attachments a third larger
+	attachmentMaxBytes, err := strconv.ParseInt(getEnv("ATTACHMENT_MAX_BYTES", "18000000"), 10, 64)
+	if err != nil || attachmentMaxBytes < 0 {
+		return nil, errors.New("Cannot convert ATTACHMENT_MAX_BYTES to a non-negative number of bytes")
+	}
+
 	config := &Config{
-		BaseURL:        getEnv("BASE_URL", "https://app.detrack.com/api/v2"),
-		APIKey:         getEnv("API_KEY", ""),
-		FetchLimit:     fetchLimit,
-		SMTPHost:       getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:       getEnv("SMTP_PORT", "587"),
-		EmailSender:    getEnv("EMAIL_SENDER", ""),
-		EmailPassword:  getEnv("EMAIL_PASSWORD", ""),
-		EmailReceivers: getEnv("EMAIL_RECEIVERS", ""), //comma separated for multiple receivers
-	}
-
-	// Validate required fields
-	if config.APIKey == "" {
+		BaseURL:               getEnv("BASE_URL", "https://app.detrack.com/api/v2"),
+		APIKey:                getEnv("API_KEY", ""),
+		FetchLimit:            fetchLimit,
+		FetchMaxRetries:       fetchMaxRetries,
+		FetchConcurrency:      fetchConcurrency,
+		SMTPHost:              getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:              getEnv("SMTP_PORT", "587"),
+		EmailSender:           getEnv("EMAIL_SENDER", ""),
+		EmailPassword:         getEnv("EMAIL_PASSWORD", ""),
+		EmailReceivers:        getEnv("EMAIL_RECEIVERS", ""), //comma separated for multiple receivers
+		AttachmentZipAbove:    attachmentZipAbove,
+		AttachmentMaxBytes:    attachmentMaxBytes,
+		AttachmentLinkBase:    getEnv("ATTACHMENT_LINK_BASE", ""),
+		AttachmentPublishDir:  getEnv("ATTACHMENT_PUBLISH_DIR", ""),
+		AttachmentUploadURL:   getEnv("ATTACHMENT_UPLOAD_URL", ""),
+		AttachmentUploadToken: getEnv("ATTACHMENT_UPLOAD_TOKEN", ""),
+		NotifyChannels:        getEnv("NOTIFY_CHANNELS", ""),
+		RecipientGroups:       getEnv("RECIPIENT_GROUPS", ""),
+		ReconcileMode:         getEnv("RECONCILE_MODE", ReconcileFlag),
+		ReportMode:            getEnv("REPORT_MODE", ReportRuns),
+		Compare:               splitList(getEnv("COMPARE", ComparePrevious+","+CompareLastYear)),
+		CompareMoverPercent:   compareMoverPercent,
+		PricePolicy:           getEnv("PRICE_POLICY", PriceQuarantine),
+		NormalizerRules:       getEnv("NORMALIZER_RULES", ""),
+		OutputFormats:         splitList(getEnv("OUTPUT_FORMATS", "xlsx")), // comma separated: xlsx, csv, json, pdf
+		JobsColumns:           splitList(getEnv("JOBS_COLUMNS", "")),       // comma separated Detrack field names
+		Pivots:                getEnv("PIVOTS", ""),
+		CacheMode:             getEnv("CACHE_MODE", CacheOff),
+		CacheDir:              getEnv("CACHE_DIR", "./data/cache"),
+		CacheSettleDays:       cacheSettleDays,
+		CacheRefreshDays:      cacheRefreshDays,
+		JobsFile:              getEnv("JOBS_FILE", ""),
+		RecordDir:             getEnv("DETRACK_RECORD_DIR", ""),
+		RecordScrub:           splitList(getEnv("DETRACK_RECORD_SCRUB", "address,deliver_to_collect_from,received_by_sent_by")),
+	}
+
+	// Validate required fields; the API key is not needed to report from the cache or a snapshot
+	if config.APIKey == "" && config.CacheMode != CacheOffline && config.JobsFile == "" {
 		return nil, errors.New("ENV: API_KEY not found")
 	}
 
-	if config.EmailPassword == "" {
-		return nil, errors.New("ENV: EMAIL_PASSWORD not found")
+	// With a channels or recipient groups file the email settings are only
+	// checked if a channel sends email. Groups alone need no EMAIL_RECEIVERS.
+	if config.NotifyChannels == "" && (config.RecipientGroups == "" || config.EmailReceivers != "") {
+		if config.EmailPassword == "" {
+			return nil, errors.New("ENV: EMAIL_PASSWORD not found")
+		}
+
+		if config.EmailSender == "" {
+			return nil, errors.New("ENV: EMAIL_SENDER not found")
+		}
+
+		if config.EmailReceivers == "" {
+			return nil, errors.New("ENV: EMAIL_RECEIVERS not found")
+		}
+	}
+
+	// Links are only sent to files that were published
+	switch {
+	case config.AttachmentPublishDir != "" && config.AttachmentUploadURL != "":
+		return nil, errors.New("ENV: set ATTACHMENT_PUBLISH_DIR or ATTACHMENT_UPLOAD_URL, not both")
+	case config.AttachmentPublishDir != "" && config.AttachmentLinkBase == "":
+		return nil, errors.New("ENV: ATTACHMENT_PUBLISH_DIR needs the ATTACHMENT_LINK_BASE it is served under")
+	case config.AttachmentLinkBase != "" && config.AttachmentPublishDir == "" && config.AttachmentUploadURL == "":
+		return nil, errors.New("ENV: ATTACHMENT_LINK_BASE needs ATTACHMENT_PUBLISH_DIR or ATTACHMENT_UPLOAD_URL to publish the files")
+	}
+
+	if len(config.OutputFormats) == 0 {
+		return nil, errors.New("ENV: OUTPUT_FORMATS must list at least one format")
 	}
 
-	if config.EmailSender == "" {
-		return nil, errors.New("ENV: EMAIL_SENDER not found")
+	if config.ReconcileMode != ReconcileFail && config.ReconcileMode != ReconcileFlag {
+		return nil, errors.New("ENV: RECONCILE_MODE must be fail or flag")
 	}
 
-	if config.EmailReceivers == "" {
-		return nil, errors.New("ENV: EMAIL_RECEIVERS not found")
+	switch config.ReportMode {
+	case ReportRuns, ReportDrivers, ReportAll:
+	default:
+		return nil, errors.New("ENV: REPORT_MODE must be runs, drivers or all")
+	}
+
+	for _, compare := range config.Compare {
+		switch compare {
+		case ComparePrevious, CompareLastYear:
+		case CompareOff:
+			if len(config.Compare) > 1 {
+				return nil, errors.New("ENV: COMPARE cannot combine off with other periods")
+			}
+			config.Compare = nil
+		default:
+			return nil, errors.New("ENV: COMPARE must list previous and/or last_year, or be off")
+		}
+	}
+
+	switch config.CacheMode {
+	case CacheOff, CacheSync, CacheRefresh, CacheOffline:
+	default:
+		return nil, errors.New("ENV: CACHE_MODE must be off, sync, refresh or offline")
+	}
+
+	switch config.PricePolicy {
+	case PriceReject, PriceZero, PriceQuarantine:
+	default:
+		return nil, errors.New("ENV: PRICE_POLICY must be reject, zero or quarantine")
 	}
 
 	return config, nil
@@ -67,4 +261,15 @@ func getEnv(key, defaultValue string) string {
 	}
 
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// splitList parses a comma separated value into trimmed, lower case, non-empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,131 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is the only currency Detrack prices are recorded in
+const Currency = "AUD"
+
+// ExcelFormat is the number format applied to money cells in the workbook
+const ExcelFormat = `"A$"#,##0.00;-"A$"#,##0.00`
+
+// ErrInvalidAmount is returned when a price string cannot be parsed
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Cents is an amount of Australian dollars stored as whole cents, so sums
+// never drift the way float64 totals do
+type Cents int64
+
+// Parse reads amounts like "10.34", "5", "-0.5", "$1,234.50" or "A$12".
+// More than two decimal places are rounded half away from zero.
+func Parse(s string) (Cents, error) {
+	raw := s
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, Currency)
+	s = strings.TrimSpace(s)
+
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	}
+
+	s = strings.TrimPrefix(s, "A$")
+	s = strings.TrimPrefix(s, "$")
+	s = strings.ReplaceAll(s, ",", "")
+
+	if s == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, raw)
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, raw)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, raw)
+	}
+
+	dollars, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || dollars > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, raw)
+	}
+
+	// Pad or trim the fraction to cents, remembering the next digit for rounding
+	frac += "000"
+	cents, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		cents++
+	}
+
+	total := Cents(dollars*100 + cents)
+	if negative {
+		total = -total
+	}
+
+	return total, nil
+}
+
+// Float64 returns the amount in dollars, for spreadsheet cells
+func (c Cents) Float64() float64 {
+	return float64(c) / 100
+}
+
+// Decimal formats the amount as a plain decimal, e.g. "-1234.50"
+func (c Cents) Decimal() string {
+	sign := ""
+	abs := int64(c)
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// String formats the amount for people, e.g. "A$1,234.50" or "-A$0.50"
+func (c Cents) String() string {
+	decimal := c.Decimal()
+
+	sign := ""
+	if strings.HasPrefix(decimal, "-") {
+		sign = "-"
+		decimal = decimal[1:]
+	}
+
+	whole, frac, _ := strings.Cut(decimal, ".")
+
+	// Group thousands
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + "A$" + grouped.String() + "." + frac
+}
+
+// MarshalJSON writes the amount as an exact decimal number, e.g. 10.34
+func (c Cents) MarshalJSON() ([]byte, error) {
+	return []byte(c.Decimal()), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package money_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    money.Cents
+		wantErr bool
+	}{
+		{in: "10.34", want: 1034},
+		{in: "5", want: 500},
+		{in: ".5", want: 50},
+		{in: "7.", want: 700},
+		{in: " 12.5 ", want: 1250},
+		{in: "-0.5", want: -50},
+
+		// Half away from zero
+		{in: "0.005", want: 1},
+		{in: "-0.005", want: -1},
+		{in: "0.004", want: 0},
+		{in: "-0.004", want: 0},
+		{in: "1.995", want: 200},
+		{in: "2.345678", want: 235},
+
+		// Currency prefixes and thousands separators
+		{in: "A$12", want: 1200},
+		{in: "$1,234.50", want: 123450},
+		{in: "AUD 99.99", want: 9999},
+		{in: "AUD-1", want: -100},
+		{in: "-A$0.50", want: -50},
+		{in: "-$3", want: -300},
+		{in: "1,000,000", want: 100000000},
+
+		// The largest whole dollar amount that fits, and the first that does not
+		{in: "92233720368547757.99", want: (math.MaxInt64/100-1)*100 + 99},
+		{in: "92233720368547758", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+
+		{in: "", wantErr: true},
+		{in: " ", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "A$", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "A$-0.50", wantErr: true},
+		{in: "+1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := money.Parse(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, money.ErrInvalidAmount) {
+					t.Errorf("Parse(%q) = %d, %v, want ErrInvalidAmount", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		cents       money.Cents
+		wantString  string
+		wantDecimal string
+	}{
+		{0, "A$0.00", "0.00"},
+		{5, "A$0.05", "0.05"},
+		{-50, "-A$0.50", "-0.50"},
+		{123450, "A$1,234.50", "1234.50"},
+		{-100000000, "-A$1,000,000.00", "-1000000.00"},
+		{math.MaxInt64, "A$92,233,720,368,547,758.07", "92233720368547758.07"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cents.String(); got != tt.wantString {
+			t.Errorf("Cents(%d).String() = %q, want %q", tt.cents, got, tt.wantString)
+		}
+		if got := tt.cents.Decimal(); got != tt.wantDecimal {
+			t.Errorf("Cents(%d).Decimal() = %q, want %q", tt.cents, got, tt.wantDecimal)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, cents := range []money.Cents{0, 1, -1, 99, -50, 1034, 123450, -123456789, (math.MaxInt64/100 - 1) * 100} {
+		for _, format := range []struct {
+			name string
+			text string
+		}{
+			{"String", cents.String()},
+			{"Decimal", cents.Decimal()},
+		} {
+			got, err := money.Parse(format.text)
+			if err != nil || got != cents {
+				t.Errorf("Parse(%s of %d = %q) = %d, %v", format.name, cents, format.text, got, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package notifier
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// base64LineLength is the longest encoded line RFC 2045 allows
+const base64LineLength = 76
+
+// attachment is a file ready to be attached, possibly a zipped copy of the report file
+type attachment struct {
+	path        string
+	name        string
+	contentType string
+	size        int64
+	temporary   bool // path is a zip to remove once sent
+
+	originalSize int64 // of the report file, before zipping
+}
+
+// skippedFile is a report file too large to attach, published instead
+type skippedFile struct {
+	name string
+	size int64
+	link string // where the file can be downloaded
+}
+
+// prepareAttachments zips the files above the zip threshold and publishes the
+// ones still above the size limit instead of attaching them. Without a
+// publisher, or when publishing fails, they are attached anyway so the file
+// is never dropped. Callers remove the attachments with cleanup.
+func (n *SMTPChannel) prepareAttachments(paths []string) ([]attachment, []skippedFile, error) {
+	attachments := []attachment{}
+	skipped := []skippedFile{}
+
+	for _, path := range paths {
+		a, err := n.prepareAttachment(path)
+		if err != nil {
+			cleanup(attachments)
+			return nil, nil, fmt.Errorf("failed to attach file %s: %w", path, err)
+		}
+
+		if n.attachmentMaxBytes > 0 && a.size > n.attachmentMaxBytes {
+			fields := []zap.Field{
+				zap.String("file", a.name),
+				zap.Int64("bytes", a.size),
+				zap.Int64("maxBytes", n.attachmentMaxBytes),
+			}
+
+			if n.publisher == nil {
+				n.logger.Error("Report file too large to attach and no ATTACHMENT_PUBLISH_DIR or ATTACHMENT_UPLOAD_URL to publish it, attaching it anyway", fields...)
+				attachments = append(attachments, a)
+				continue
+			}
+
+			link, err := n.publisher.Publish(path)
+			if err != nil {
+				n.logger.Error("Failed to publish report file too large to attach, attaching it anyway", append(fields, zap.Error(err))...)
+				attachments = append(attachments, a)
+				continue
+			}
+
+			n.logger.Warn("Report file too large to attach, sending a link instead", append(fields, zap.String("link", link))...)
+			skipped = append(skipped, skippedFile{name: filepath.Base(path), size: a.originalSize, link: link})
+			cleanup([]attachment{a})
+			continue
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, skipped, nil
+}
+
+func (n *SMTPChannel) prepareAttachment(path string) (attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return attachment{}, err
+	}
+
+	a := attachment{path: path, name: filepath.Base(path), size: info.Size(), originalSize: info.Size()}
+
+	if n.attachmentZipAbove > 0 && a.size > n.attachmentZipAbove && !strings.EqualFold(filepath.Ext(path), ".zip") {
+		zipped, err := zipFile(path)
+		if err != nil {
+			return attachment{}, fmt.Errorf("failed to zip: %w", err)
+		}
+		zipInfo, err := os.Stat(zipped)
+		if err != nil {
+			os.Remove(zipped)
+			return attachment{}, err
+		}
+
+		// Formats like XLSX are compressed already and do not get smaller
+		if zipInfo.Size() < a.size {
+			n.logger.Info("Zipped report file before attaching",
+				zap.String("file", a.name),
+				zap.Int64("bytes", a.size),
+				zap.Int64("zippedBytes", zipInfo.Size()),
+			)
+			return attachment{
+				path:         zipped,
+				name:         a.name + ".zip",
+				contentType:  "application/zip",
+				size:         zipInfo.Size(),
+				temporary:    true,
+				originalSize: a.size,
+			}, nil
+		}
+		os.Remove(zipped)
+	}
+
+	a.contentType, err = contentType(path)
+	if err != nil {
+		return attachment{}, err
+	}
+	return a, nil
+}
+
+// cleanup removes the temporary zips
+func cleanup(attachments []attachment) {
+	for _, a := range attachments {
+		if a.temporary {
+			os.Remove(a.path)
+		}
+	}
+}
+
+// zipFile compresses a file into a temporary zip holding only that file
+func zipFile(path string) (string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	target, err := os.CreateTemp("", filepath.Base(path)+"-*.zip")
+	if err != nil {
+		return "", err
+	}
+
+	archive := zip.NewWriter(target)
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: filepath.Base(path), Method: zip.Deflate})
+	if err == nil {
+		_, err = io.Copy(entry, source)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target.Name())
+		return "", err
+	}
+
+	return target.Name(), nil
+}
+
+// attachFile streams a file into a base64 part, wrapped at 76 characters
+func attachFile(writer *multipart.Writer, a attachment) error {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	partHeader := textproto.MIMEHeader{}
+	partHeader.Set("Content-Type", a.contentType)
+	partHeader.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.name}))
+	partHeader.Set("Content-Transfer-Encoding", "base64")
+
+	part, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment part: %w", err)
+	}
+
+	lines := &lineWrapper{w: part, width: base64LineLength}
+	encoder := base64.NewEncoder(base64.StdEncoding, lines)
+	if _, err := io.Copy(encoder, file); err != nil {
+		encoder.Close()
+		return fmt.Errorf("failed to write encoded data: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to close encoder: %w", err)
+	}
+
+	return lines.end()
+}
+
+// lineWrapper breaks the written bytes into CRLF terminated lines of width bytes
+type lineWrapper struct {
+	w      io.Writer
+	width  int
+	column int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.column == l.width {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.column = 0
+		}
+
+		chunk := min(len(p), l.width-l.column)
+		n, err := l.w.Write(p[:chunk])
+		written += n
+		l.column += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+// end terminates the last line
+func (l *lineWrapper) end() error {
+	if l.column == 0 {
+		return nil
+	}
+	l.column = 0
+	_, err := io.WriteString(l.w, "\r\n")
+	return err
+}
+
+// attachmentTypes covers the report formats; the runtime image has no mime.types
+var attachmentTypes = map[string]string{
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".csv":  "text/csv; charset=utf-8",
+	".json": "application/json",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+}
+
+// contentType picks the attachment MIME type from the file extension, or
+// from the first bytes of the file when the extension is not known
+func contentType(filePath string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if t, ok := attachmentTypes[ext]; ok {
+		return t, nil
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	// DetectContentType falls back to application/octet-stream
+	return http.DetectContentType(head[:n]), nil
+}
+
+// skippedText tells the receivers about the files that were not attached
+func skippedText(skipped []skippedFile) string {
+	var text strings.Builder
+	text.WriteString("These report files were too large to attach:\n")
+	for _, file := range skipped {
+		fmt.Fprintf(&text, "- %s (%s): %s\n", file.name, formatSize(file.size), file.link)
+	}
+	return text.String()
+}
+
+// skippedHTML is skippedText for the HTML body
+func skippedHTML(skipped []skippedFile) string {
+	var text strings.Builder
+	text.WriteString(`<div style="max-width:640px;margin:0 auto;padding:16px;font-family:Arial,Helvetica,sans-serif;font-size:13px;">`)
+	text.WriteString("<p>These report files were too large to attach:</p><ul>")
+	for _, file := range skipped {
+		name := html.EscapeString(fmt.Sprintf("%s (%s)", file.name, formatSize(file.size)))
+		fmt.Fprintf(&text, `<li><a href="%s">%s</a></li>`, html.EscapeString(file.link), name)
+	}
+	text.WriteString("</ul></div>")
+	return text.String()
+}
+
+// formatSize shows a byte count in MB, or KB below 1 MB, e.g. "23.4 MB"
+func formatSize(bytes int64) string {
+	if bytes < 1<<20 {
+		return fmt.Sprintf("%.0f KB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
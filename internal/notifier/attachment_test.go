@@ -0,0 +1,128 @@
+package notifier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+)
+
+func TestPrepareAttachments(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "disk full", http.StatusInsufficientStorage)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name string
+		cfg  config.Config // publisher settings
+
+		wantAttached []string
+		wantLinked   []string
+		wantCopied   bool // the large file is in the publish dir
+	}{
+		{
+			name:         "without a publisher the large file is still attached",
+			wantAttached: []string{"large.csv", "small.csv"},
+		},
+		{
+			name:         "publish dir",
+			cfg:          config.Config{AttachmentLinkBase: "https://reports.example.com/detrack/"},
+			wantAttached: []string{"small.csv"},
+			wantLinked:   []string{"https://reports.example.com/detrack/large.csv"},
+			wantCopied:   true,
+		},
+		{
+			name:         "failed upload keeps the attachment",
+			cfg:          config.Config{AttachmentUploadURL: failing.URL},
+			wantAttached: []string{"large.csv", "small.csv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			large := filepath.Join(dir, "large.csv")
+			small := filepath.Join(dir, "small.csv")
+			if err := os.WriteFile(large, []byte(strings.Repeat("a,b\n", 100)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(small, []byte("a,b\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := tt.cfg
+			cfg.AttachmentMaxBytes = 100
+			publishDir := filepath.Join(dir, "published")
+			if cfg.AttachmentLinkBase != "" {
+				cfg.AttachmentPublishDir = publishDir
+			}
+			channel := NewSMTPChannel(zap.NewNop(), &cfg, "email", []string{"x@example.com"}, NewPublisher(zap.NewNop(), &cfg))
+
+			attachments, skipped, err := channel.prepareAttachments([]string{large, small})
+			if err != nil {
+				t.Fatalf("prepareAttachments: %v", err)
+			}
+			defer cleanup(attachments)
+
+			var attached, linked []string
+			for _, a := range attachments {
+				attached = append(attached, a.name)
+			}
+			for _, file := range skipped {
+				linked = append(linked, file.link)
+			}
+			if strings.Join(attached, ",") != strings.Join(tt.wantAttached, ",") {
+				t.Errorf("attached %q, want %q", attached, tt.wantAttached)
+			}
+			if strings.Join(linked, ",") != strings.Join(tt.wantLinked, ",") {
+				t.Errorf("linked %q, want %q", linked, tt.wantLinked)
+			}
+
+			_, err = os.Stat(filepath.Join(publishDir, "large.csv"))
+			if copied := err == nil; copied != tt.wantCopied {
+				t.Errorf("large.csv published = %v, want %v", copied, tt.wantCopied)
+			}
+		})
+	}
+}
+
+func TestPublisherUpload(t *testing.T) {
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		uploads = append(uploads, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "report 2026-02.xlsx")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{AttachmentUploadURL: srv.URL + "/detrack/", AttachmentUploadToken: "secret"}
+	publisher := NewPublisher(zap.NewNop(), &cfg)
+	for range 2 {
+		link, err := publisher.Publish(path)
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		if want := srv.URL + "/detrack/report%202026-02.xlsx"; link != want {
+			t.Errorf("link = %q, want %q", link, want)
+		}
+	}
+
+	// Published once for both calls
+	if len(uploads) != 1 || uploads[0] != "/detrack/report 2026-02.xlsx" {
+		t.Errorf("uploads = %q, want one to /detrack/report 2026-02.xlsx", uploads)
+	}
+}
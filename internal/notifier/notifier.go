@@ -1,125 +1,164 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"mime/multipart"
-	"net/smtp"
-	"net/textproto"
 	"os"
-	"path/filepath"
 	"strings"
 
-	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
 	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
 )
 
-type Notifier struct {
-	logger         *zap.Logger
-	smtpHost       string
-	smtpPort       string
-	emailSender    string
-	emailPassword  string
-	emailReceivers []string // Changed to slice
+// Channel is somewhere the report is sent, e.g. an email list or a chat room
+type Channel interface {
+	Name() string
+	Send(msg Message) error
 }
 
-func NewNotifier(logger *zap.Logger, cfg *config.Config) *Notifier {
-	// Parse comma-separated receivers
-	receivers := strings.Split(cfg.EmailReceivers, ",")
-	for i := range receivers {
-		receivers[i] = strings.TrimSpace(receivers[i])
-	}
-
-	return &Notifier{
-		logger:         logger,
-		smtpHost:       cfg.SMTPHost,
-		smtpPort:       cfg.SMTPPort,
-		emailSender:    cfg.EmailSender,
-		emailPassword:  cfg.EmailPassword,
-		emailReceivers: receivers,
-	}
+// Message is what every channel sends; each picks the parts it can show
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string       // optional, for channels that show HTML
+	Report  *ReportEmail // the summary of the numbers; nil for alerts
+	Files   []string     // report files, attached or linked to
 }
 
-func (n *Notifier) Send(subject, body string, attachmentPaths []string) error {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
+// Channel types in the channels file
+const (
+	ChannelSMTP    = "smtp"
+	ChannelSlack   = "slack"
+	ChannelTeams   = "teams"
+	ChannelWebhook = "webhook"
+)
 
-	// Build headers
-	headers := textproto.MIMEHeader{}
-	headers.Set("From", n.emailSender)
-	headers.Set("To", strings.Join(n.emailReceivers, ", "))
-	headers.Set("Subject", subject)
-	headers.Set("MIME-Version", "1.0")
-	headers.Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+// ChannelConfig is one entry of the NOTIFY_CHANNELS file
+type ChannelConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // smtp, slack, teams or webhook
 
-	for k, v := range headers {
-		fmt.Fprintf(&buf, "%s: %s\r\n", k, v[0])
-	}
-	buf.WriteString("\r\n")
+	// Receivers of an smtp channel; defaults to EMAIL_RECEIVERS
+	Receivers []string `json:"receivers"`
 
-	// Body
-	bodyPart, err := writer.CreatePart(textproto.MIMEHeader{
-		"Content-Type": {"text/plain; charset=utf-8"},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create body part: %w", err)
-	}
-	
-	if _, err := bodyPart.Write([]byte(body)); err != nil {
-		return fmt.Errorf("failed to write body: %w", err)
-	}
+	// URL of a slack, teams or webhook channel. Webhook URLs are secrets, so
+	// URLEnv names an environment variable holding it instead.
+	URL    string `json:"url"`
+	URLEnv string `json:"url_env"`
+	// Headers are added to webhook requests, e.g. Authorization
+	Headers map[string]string `json:"headers"`
+}
+
+// Notifier sends every message to all its channels
+type Notifier struct {
+	logger   *zap.Logger
+	channels []Channel
+}
 
-	// Attachments
-	for _, path := range attachmentPaths {
-		if err := attachFile(writer, path); err != nil {
-			return fmt.Errorf("failed to attach file %s: %w", path, err)
+// NewNotifier builds the channels of the NOTIFY_CHANNELS file, or a single
+// email channel to EMAIL_RECEIVERS without one
+func NewNotifier(logger *zap.Logger, cfg *config.Config) (*Notifier, error) {
+	configs := []ChannelConfig{{Name: "email", Type: ChannelSMTP}}
+	if cfg.NotifyChannels != "" {
+		loaded, err := LoadChannelConfigs(cfg.NotifyChannels)
+		if err != nil {
+			return nil, err
 		}
+		configs = loaded
 	}
 
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close writer: %w", err)
-	}
+	return NewNotifierWithChannels(logger, cfg, configs)
+}
 
-	// Use configured SMTP settings
-	auth := smtp.PlainAuth("", n.emailSender, n.emailPassword, n.smtpHost)
-	addr := fmt.Sprintf("%s:%s", n.smtpHost, n.smtpPort)
-	
-	if err := smtp.SendMail(addr, auth, n.emailSender, n.emailReceivers, buf.Bytes()); err != nil {
-		n.logger.Error("Failed to send email", zap.Error(err))
-		return fmt.Errorf("failed to send email: %w", err)
+// NewNotifierWithChannels builds the given channels, e.g. those of a recipient group
+func NewNotifierWithChannels(logger *zap.Logger, cfg *config.Config, configs []ChannelConfig) (*Notifier, error) {
+	n := &Notifier{logger: logger}
+	publisher := NewPublisher(logger, cfg)
+	for _, channelConfig := range configs {
+		channel, err := NewChannel(logger, cfg, publisher, channelConfig)
+		if err != nil {
+			return nil, err
+		}
+		n.channels = append(n.channels, channel)
 	}
 
-	n.logger.Info("Email sent successfully")
-	return nil
+	return n, nil
 }
 
-func attachFile(writer *multipart.Writer, filePath string) error {
-	fileData, err := os.ReadFile(filePath)
+// LoadChannelConfigs reads a JSON list of channels
+func LoadChannelConfigs(path string) ([]ChannelConfig, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read notify channels: %w", err)
 	}
 
-	partHeader := textproto.MIMEHeader{}
-	partHeader.Set("Content-Type", "text/csv")
-	partHeader.Set("Content-Disposition",
-		fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(filePath)))
-	partHeader.Set("Content-Transfer-Encoding", "base64")
+	var configs []ChannelConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse notify channels %s: %w", path, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("notify channels %s lists no channel", path)
+	}
 
-	part, err := writer.CreatePart(partHeader)
-	if err != nil {
-		return fmt.Errorf("failed to create attachment part: %w", err)
+	return configs, nil
+}
+
+// NewChannel creates the channel described by one channels file entry.
+// Files are linked to once publisher has put them online; it may be nil.
+func NewChannel(logger *zap.Logger, cfg *config.Config, publisher *Publisher, c ChannelConfig) (Channel, error) {
+	if strings.TrimSpace(c.Name) == "" {
+		return nil, errors.New("notify channel without a name")
 	}
 
-	encoder := base64.NewEncoder(base64.StdEncoding, part)
-	if _, err := encoder.Write(fileData); err != nil {
-		encoder.Close()
-		return fmt.Errorf("failed to write encoded data: %w", err)
+	switch c.Type {
+	case ChannelSMTP:
+		if cfg.EmailSender == "" || cfg.EmailPassword == "" {
+			return nil, fmt.Errorf("channel %q: EMAIL_SENDER and EMAIL_PASSWORD are required to send email", c.Name)
+		}
+
+		receivers := c.Receivers
+		if len(receivers) == 0 {
+			receivers = strings.Split(cfg.EmailReceivers, ",")
+		}
+		for i := range receivers {
+			receivers[i] = strings.TrimSpace(receivers[i])
+		}
+		if len(receivers) == 0 || receivers[0] == "" {
+			return nil, fmt.Errorf("channel %q has no receivers", c.Name)
+		}
+
+		return NewSMTPChannel(logger, cfg, c.Name, receivers, publisher), nil
+
+	case ChannelSlack, ChannelTeams, ChannelWebhook:
+		url := c.URL
+		if c.URLEnv != "" {
+			url = os.Getenv(c.URLEnv)
+		}
+		if url == "" {
+			return nil, fmt.Errorf("channel %q has no url", c.Name)
+		}
+
+		return NewWebhookChannel(logger, c.Name, c.Type, url, c.Headers, publisher), nil
+
+	default:
+		return nil, fmt.Errorf("channel %q: unknown type %q (want smtp, slack, teams or webhook)", c.Name, c.Type)
 	}
-	
-	if err := encoder.Close(); err != nil {
-		return fmt.Errorf("failed to close encoder: %w", err)
+}
+
+// Send sends the message on every channel. A failing channel does not stop
+// the others; the error lists every channel that failed.
+func (n *Notifier) Send(msg Message) error {
+	var errs []error
+	for _, channel := range n.channels {
+		if err := channel.Send(msg); err != nil {
+			n.logger.Error("Failed to notify channel", zap.String("channel", channel.Name()), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+			continue
+		}
+		n.logger.Info("Notified channel", zap.String("channel", channel.Name()))
 	}
 
-	return nil
-}
\ No newline at end of file
+	return errors.Join(errs...)
+}
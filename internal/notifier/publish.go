@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+)
+
+// uploadTimeout bounds each upload; the files are the ones too large to email
+const uploadTimeout = 5 * time.Minute
+
+// Publisher puts report files where the receivers can download them, either
+// by copying them into a served directory or by uploading them. Each file is
+// published once, however many channels link to it.
+type Publisher struct {
+	logger      *zap.Logger
+	dir         string // ATTACHMENT_PUBLISH_DIR
+	uploadURL   string // ATTACHMENT_UPLOAD_URL
+	uploadToken string
+	linkBase    string
+	client      *http.Client
+
+	mu        sync.Mutex
+	published map[string]string // file path to its link
+}
+
+// NewPublisher publishes as set up in cfg; nil when no publisher is set, in
+// which case nothing is linked to
+func NewPublisher(logger *zap.Logger, cfg *config.Config) *Publisher {
+	if cfg.AttachmentPublishDir == "" && cfg.AttachmentUploadURL == "" {
+		return nil
+	}
+
+	linkBase := cfg.AttachmentLinkBase
+	if linkBase == "" {
+		linkBase = cfg.AttachmentUploadURL
+	}
+
+	return &Publisher{
+		logger:      logger,
+		dir:         cfg.AttachmentPublishDir,
+		uploadURL:   cfg.AttachmentUploadURL,
+		uploadToken: cfg.AttachmentUploadToken,
+		linkBase:    linkBase,
+		client:      &http.Client{Timeout: uploadTimeout},
+		published:   map[string]string{},
+	}
+}
+
+// Publish copies or uploads the file and returns its download link
+func (p *Publisher) Publish(path string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if link, ok := p.published[path]; ok {
+		return link, nil
+	}
+
+	var err error
+	if p.dir != "" {
+		err = p.copy(path)
+	} else {
+		err = p.upload(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to publish %s: %w", filepath.Base(path), err)
+	}
+
+	link := fileLink(p.linkBase, path)
+	p.published[path] = link
+	p.logger.Info("Published report file", zap.String("file", filepath.Base(path)), zap.String("link", link))
+	return link, nil
+}
+
+// copy writes the file into the publish directory under a temporary name and
+// renames it, so a half copied file is never served
+func (p *Publisher) copy(path string) error {
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		return err
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.CreateTemp(p.dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, source)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(target.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(target.Name(), filepath.Join(p.dir, filepath.Base(path)))
+	}
+	if err != nil {
+		os.Remove(target.Name())
+		return err
+	}
+
+	return nil
+}
+
+// upload PUTs the file to <upload URL>/<file name>
+func (p *Publisher) upload(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	fileType, err := contentType(path)
+	if err != nil {
+		return err
+	}
+
+	target := strings.TrimRight(p.uploadURL, "/") + "/" + url.PathEscape(filepath.Base(path))
+	req, err := http.NewRequest(http.MethodPut, target, file)
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", fileType)
+	if p.uploadToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.uploadToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("upload returned %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+
+	return nil
+}
+
+// fileLink is where a published file is downloaded from, e.g.
+// https://reports.example.com/detrack/<name>
+func fileLink(base, path string) string {
+	return strings.TrimRight(base, "/") + "/" + url.PathEscape(filepath.Base(path))
+}
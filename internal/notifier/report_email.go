@@ -0,0 +1,263 @@
+package notifier
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"unicode/utf8"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/output"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+var (
+	//go:embed templates/report.html.tmpl
+	reportHTML string
+	//go:embed templates/report.txt.tmpl
+	reportText string
+
+	reportHTMLTemplate = htmltemplate.Must(htmltemplate.New("report.html").Parse(reportHTML))
+	reportTextTemplate = texttemplate.Must(texttemplate.New("report.txt").Parse(reportText))
+)
+
+// topRowsLimit is how many rows the email shows of the first summary
+const topRowsLimit = 10
+
+// moversLimit is how many rows the email lists per comparison and measure
+const moversLimit = 5
+
+// ReportEmail is the summary sent with the report files, rendered as HTML
+// with a plain text fallback
+type ReportEmail struct {
+	From string // first day of the period
+	To   string // last day of the period
+	// Notices are shown before anything else, e.g. that totals do not reconcile
+	Notices []string
+
+	Figures    []Figure // headline totals
+	TopRows    *Table   // the first summary's rows with the most freight revenue
+	Comparison *Table   // the totals against each earlier period
+	// MoverPercent is the change at which a row is listed in Movers
+	MoverPercent float64
+	Movers       []MoverList
+}
+
+// Figure is one headline number
+type Figure struct {
+	Label string
+	Value string
+}
+
+// Table is a small table of text cells
+type Table struct {
+	Title   string     `json:"title"`
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+}
+
+// Text lays the table out in fixed-width columns for the plain text email,
+// the headers underlined
+func (t *Table) Text() string {
+	widths := make([]int, len(t.Headers))
+	for i, header := range t.Headers {
+		widths[i] = utf8.RuneCountInString(header)
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+			}
+		}
+	}
+
+	underline := make([]string, len(widths))
+	for i, width := range widths {
+		underline[i] = strings.Repeat("-", width)
+	}
+
+	var text strings.Builder
+	for _, cells := range append([][]string{t.Headers, underline}, t.Rows...) {
+		var line strings.Builder
+		for i, cell := range cells {
+			if i >= len(widths) {
+				break
+			}
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(cell)
+			line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+		text.WriteString(strings.TrimRight(line.String(), " "))
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// MoverList is the rows that moved the most for one measure and earlier period
+type MoverList struct {
+	Title string   `json:"title"`
+	Lines []string `json:"lines"`
+}
+
+// NewReportEmail summarizes the document for the email body
+func NewReportEmail(doc *output.Document, moverPercent float64) *ReportEmail {
+	e := &ReportEmail{
+		From:         doc.Period.From.Format(period.DateLayout),
+		To:           doc.Period.LastDay().Format(period.DateLayout),
+		MoverPercent: moverPercent,
+	}
+
+	primary := doc.Summaries[0]
+	totals := primary.Report.Totals
+	e.Figures = []Figure{
+		{"Orders delivered", strconv.Itoa(totals.NumOrdersDelivered)},
+		{"Orders picked up", strconv.Itoa(totals.NumOrdersPickedUp)},
+		{"Parts", strconv.Itoa(totals.NumPartsDelivered + totals.NumPartsPickedUp)},
+		{"Freight revenue", totals.FreightRevenue.String()},
+	}
+	if excluded := primary.Report.ExcludedTotal(); excluded > 0 {
+		e.Figures = append(e.Figures, Figure{"Jobs excluded", strconv.Itoa(excluded)})
+	}
+
+	e.TopRows = topRowsTable(primary)
+
+	for _, summary := range doc.Summaries {
+		if len(summary.Baselines) == 0 {
+			continue
+		}
+		if e.Comparison == nil {
+			e.Comparison = comparisonTable(summary)
+		}
+		e.Movers = append(e.Movers, moverLists(summary, moverPercent)...)
+	}
+
+	return e
+}
+
+// Render returns the plain text and the HTML body
+func (e *ReportEmail) Render() (text, html string, err error) {
+	var buf bytes.Buffer
+	if err := reportTextTemplate.Execute(&buf, e); err != nil {
+		return "", "", fmt.Errorf("failed to render text email: %w", err)
+	}
+	text = buf.String()
+
+	buf.Reset()
+	if err := reportHTMLTemplate.Execute(&buf, e); err != nil {
+		return "", "", fmt.Errorf("failed to render HTML email: %w", err)
+	}
+
+	return text, buf.String(), nil
+}
+
+// topRowsTable lists the rows with the most freight revenue
+func topRowsTable(summary output.Summary) *Table {
+	rows := append([]report.Row{}, summary.Report.Rows...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].FreightRevenue > rows[j].FreightRevenue
+	})
+	if len(rows) > topRowsLimit {
+		rows = rows[:topRowsLimit]
+	}
+
+	t := &Table{
+		Title:   summary.Name + ": top rows by freight revenue",
+		Headers: []string{},
+	}
+	for _, name := range summary.Report.GroupBy {
+		t.Headers = append(t.Headers, strings.ReplaceAll(name, "_", " "))
+	}
+	t.Headers = append(t.Headers, "orders", "parts", "freight revenue")
+	for _, baseline := range summary.Baselines {
+		t.Headers = append(t.Headers, "vs "+strings.ReplaceAll(baseline.Name, "_", " "))
+	}
+
+	for _, row := range rows {
+		cells := append([]string{}, row.Keys...)
+		cells = append(cells,
+			strconv.Itoa(row.NumOrdersDelivered+row.NumOrdersPickedUp),
+			strconv.Itoa(row.NumPartsDelivered+row.NumPartsPickedUp),
+			row.FreightRevenue.String(),
+		)
+		for _, baseline := range summary.Baselines {
+			cells = append(cells, percentText(baseline.Delta(row, report.FreightMeasure)))
+		}
+		t.Rows = append(t.Rows, cells)
+	}
+
+	return t
+}
+
+// comparisonTable shows each compared total this period and in the earlier ones
+func comparisonTable(summary output.Summary) *Table {
+	t := &Table{Title: summary.Name + ": totals compared", Headers: []string{"", "this period"}}
+	for _, baseline := range summary.Baselines {
+		name := strings.ReplaceAll(baseline.Name, "_", " ")
+		t.Headers = append(t.Headers, name, "change")
+	}
+
+	for _, measure := range report.ComparedMeasures {
+		cells := []string{strings.ReplaceAll(measure.Name, "_", " "), measure.FormatValue(measure.Value(summary.Report.Totals))}
+		for _, baseline := range summary.Baselines {
+			delta := baseline.Delta(summary.Report.Totals, measure)
+			cells = append(cells, measure.FormatValue(delta.Before), percentText(delta))
+		}
+		t.Rows = append(t.Rows, cells)
+	}
+
+	return t
+}
+
+// moverLists lists the rows whose orders or freight revenue changed by at least minPercent
+func moverLists(summary output.Summary, minPercent float64) []MoverList {
+	lists := []MoverList{}
+	for _, baseline := range summary.Baselines {
+		// Parts follow orders, so they are left out of the list
+		for _, measure := range []report.Measure{report.OrdersMeasure, report.FreightMeasure} {
+			movers := baseline.Movers(summary.Report, measure, minPercent, moversLimit)
+			if len(movers) == 0 {
+				continue
+			}
+
+			list := MoverList{Title: fmt.Sprintf("%s, %s compared with %s (%s)",
+				summary.Name,
+				strings.ReplaceAll(measure.Name, "_", " "),
+				strings.ReplaceAll(baseline.Name, "_", " "),
+				baseline.Period,
+			)}
+			for _, mover := range movers {
+				line := fmt.Sprintf("%s: %s -> %s (%s)",
+					strings.Join(mover.Keys, " / "),
+					measure.FormatValue(mover.Delta.Before),
+					measure.FormatValue(mover.Delta.After),
+					percentText(mover.Delta),
+				)
+				if mover.Gone {
+					line += ", no jobs this period"
+				}
+				list.Lines = append(list.Lines, line)
+			}
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
+
+// percentText formats a change as e.g. "+12.5%", or "new" when there was nothing before
+func percentText(delta report.Delta) string {
+	percent, ok := delta.Percent()
+	if !ok {
+		if delta.After == 0 {
+			return "-"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", percent)
+}
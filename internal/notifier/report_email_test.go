@@ -0,0 +1,101 @@
+package notifier
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReportEmailRender(t *testing.T) {
+	e := &ReportEmail{
+		From:    "2026-02-01",
+		To:      "2026-02-28",
+		Figures: []Figure{{"Orders delivered", "120"}, {"Freight revenue", "A$534.04"}},
+		Comparison: &Table{
+			Title:   "Report: totals compared",
+			Headers: []string{"", "this period", "previous", "change"},
+			Rows: [][]string{
+				{"orders", "120", "100", "+20.0%"},
+				{"freight revenue", "A$534.04", "A$500.00", "+6.8%"},
+			},
+		},
+		MoverPercent: 20,
+		Movers:       []MoverList{{Title: "Report, orders compared with previous", Lines: []string{"SOUTH: 10 -> 0 (-100.0%), no jobs this period"}}},
+		TopRows: &Table{
+			Title:   "Report: top rows by freight revenue",
+			Headers: []string{"run number", "orders", "parts", "freight revenue", "vs previous"},
+			Rows: [][]string{
+				{"NORTH", "80", "95", "A$400.00", "+12.5%"},
+				{"EAST", "40", "41", "A$134.04", "new"},
+			},
+		},
+	}
+
+	text, body, err := e.Render()
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	// html/template escapes e.g. the + of a change
+	body = html.UnescapeString(body)
+
+	// Both bodies show every part, and every cell of both tables
+	for _, table := range []*Table{e.Comparison, e.TopRows} {
+		for _, want := range append([]string{table.Title}, table.Rows[0]...) {
+			if !strings.Contains(text, want) {
+				t.Errorf("text body is missing %q:\n%s", want, text)
+			}
+			if !strings.Contains(body, want) {
+				t.Errorf("HTML body is missing %q", want)
+			}
+		}
+	}
+	for _, want := range []string{"Freight revenue: A$534.04", "Big movers (20% or more):", "- SOUTH: 10 -> 0"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text body is missing %q:\n%s", want, text)
+		}
+	}
+
+	// The parts come in the order of the HTML body
+	order := []string{"Freight revenue:", e.Comparison.Title, "Big movers", e.TopRows.Title, "Thanks"}
+	last := -1
+	for _, part := range order {
+		i := strings.Index(text, part)
+		if i < last {
+			t.Errorf("text body shows %q out of order:\n%s", part, text)
+		}
+		last = i
+	}
+
+	// Fixed-width columns: each value starts under its header
+	lines := strings.Split(text, "\n")
+	header := slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, "run number") })
+	if header < 0 || header+3 >= len(lines) {
+		t.Fatalf("text body has no top rows table:\n%s", text)
+	}
+	column := strings.Index(lines[header], "freight revenue")
+	for _, line := range lines[header+2 : header+4] {
+		if !strings.HasPrefix(line[column:], "A$") {
+			t.Errorf("freight revenue is not in its column:\n%s\n%s", lines[header], line)
+		}
+	}
+	if !strings.HasPrefix(lines[header+1], "----------") {
+		t.Errorf("headers are not underlined:\n%s", strings.Join(lines[header:header+2], "\n"))
+	}
+}
+
+func TestTableText(t *testing.T) {
+	table := &Table{
+		Headers: []string{"", "this period", "previous"},
+		Rows:    [][]string{{"orders", "120", "100"}, {"freight revenue", "A$534.04", "A$500.00"}},
+	}
+
+	want := "" +
+		"                 this period  previous\n" +
+		"---------------  -----------  --------\n" +
+		"orders           120          100\n" +
+		"freight revenue  A$534.04     A$500.00\n"
+	if got := table.Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
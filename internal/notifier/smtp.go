@@ -0,0 +1,246 @@
+package notifier
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"go.uber.org/zap"
+)
+
+// SMTPChannel emails the report, with the files attached
+type SMTPChannel struct {
+	name           string
+	logger         *zap.Logger
+	smtpHost       string
+	smtpPort       string
+	emailSender    string
+	emailPassword  string
+	emailReceivers []string // Changed to slice
+
+	// Attachment limits in bytes; 0 turns them off
+	attachmentZipAbove int64
+	attachmentMaxBytes int64
+	// publisher puts the files too large to attach online; nil when not set up
+	publisher *Publisher
+}
+
+// NewSMTPChannel sends with the SMTP settings of cfg to the given receivers
+func NewSMTPChannel(logger *zap.Logger, cfg *config.Config, name string, receivers []string, publisher *Publisher) *SMTPChannel {
+	return &SMTPChannel{
+		name:           name,
+		logger:         logger,
+		smtpHost:       cfg.SMTPHost,
+		smtpPort:       cfg.SMTPPort,
+		emailSender:    cfg.EmailSender,
+		emailPassword:  cfg.EmailPassword,
+		emailReceivers: receivers,
+
+		attachmentZipAbove: cfg.AttachmentZipAbove,
+		attachmentMaxBytes: cfg.AttachmentMaxBytes,
+		publisher:          publisher,
+	}
+}
+
+// Name returns the channel name from the channels file
+func (n *SMTPChannel) Name() string {
+	return n.name
+}
+
+// Send emails the HTML body when there is one, with the text body as the
+// alternative for clients that do not show HTML, and the files attached
+func (n *SMTPChannel) Send(msg Message) error {
+	return n.send(msg.Subject, msg.Text, msg.HTML, msg.Files)
+}
+
+func (n *SMTPChannel) send(subject, body, html string, attachmentPaths []string) error {
+	attachments, skipped, err := n.prepareAttachments(attachmentPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup(attachments)
+
+	// Point to the files that were left out instead
+	if len(skipped) > 0 {
+		body = strings.TrimRight(body, "\n") + "\n\n" + skippedText(skipped)
+		if html != "" {
+			html = insertBeforeBodyEnd(html, skippedHTML(skipped))
+		}
+	}
+
+	// Use configured SMTP settings
+	auth := smtp.PlainAuth("", n.emailSender, n.emailPassword, n.smtpHost)
+	addr := fmt.Sprintf("%s:%s", n.smtpHost, n.smtpPort)
+
+	// The message is written straight to the server, so attachments are
+	// never held in memory
+	err = n.deliver(addr, auth, func(w io.Writer) error {
+		return n.writeMessage(w, subject, body, html, attachments)
+	})
+	if err != nil {
+		n.logger.Error("Failed to send email", zap.String("channel", n.name), zap.Error(err))
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	n.logger.Info("Email sent successfully", zap.String("channel", n.name))
+	return nil
+}
+
+// deliver does what smtp.SendMail does, but lets write stream the message
+func (n *SMTPChannel) deliver(addr string, auth smtp.Auth, write func(w io.Writer) error) error {
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.smtpHost}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.emailSender); err != nil {
+		return err
+	}
+	for _, receiver := range n.emailReceivers {
+		if err := client.Rcpt(receiver); err != nil {
+			return err
+		}
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(data)
+	if err := write(buffered); err != nil {
+		return err // closing the connection without ending DATA drops the message
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// writeMessage writes the headers, the body and the attachments
+func (n *SMTPChannel) writeMessage(w io.Writer, subject, body, html string, attachments []attachment) error {
+	writer := multipart.NewWriter(w)
+
+	// Build headers
+	headers := textproto.MIMEHeader{}
+	headers.Set("From", n.emailSender)
+	headers.Set("To", strings.Join(n.emailReceivers, ", "))
+	headers.Set("Subject", mime.QEncoding.Encode("utf-8", subject))
+	headers.Set("MIME-Version", "1.0")
+	headers.Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+
+	for k, v := range headers {
+		if _, err := fmt.Fprintf(w, "%s: %s\r\n", k, v[0]); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+
+	// Body
+	if html != "" {
+		if err := writeAlternative(writer, body, html); err != nil {
+			return err
+		}
+	} else {
+		bodyPart, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"text/plain; charset=utf-8"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create body part: %w", err)
+		}
+
+		if _, err := bodyPart.Write([]byte(body)); err != nil {
+			return fmt.Errorf("failed to write body: %w", err)
+		}
+	}
+
+	// Attachments
+	for _, a := range attachments {
+		if err := attachFile(writer, a); err != nil {
+			return fmt.Errorf("failed to attach file %s: %w", a.path, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	return nil
+}
+
+// insertBeforeBodyEnd adds markup at the end of an HTML body
+func insertBeforeBodyEnd(html, markup string) string {
+	if i := strings.LastIndex(html, "</body>"); i >= 0 {
+		return html[:i] + markup + html[i:]
+	}
+	return html + markup
+}
+
+// writeAlternative writes a multipart/alternative part holding the text and
+// the HTML body, in that order so clients pick the HTML one when they can
+func writeAlternative(writer *multipart.Writer, text, html string) error {
+	var alternatives bytes.Buffer
+	alternative := multipart.NewWriter(&alternatives)
+
+	for _, body := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		part, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {body.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create body part: %w", err)
+		}
+
+		// Quoted-printable keeps long HTML lines under the SMTP line limit
+		encoder := quotedprintable.NewWriter(part)
+		if _, err := encoder.Write([]byte(body.content)); err != nil {
+			return fmt.Errorf("failed to write body: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("failed to write body: %w", err)
+		}
+	}
+
+	if err := alternative.Close(); err != nil {
+		return fmt.Errorf("failed to close body parts: %w", err)
+	}
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create body part: %w", err)
+	}
+
+	_, err = part.Write(alternatives.Bytes())
+	return err
+}
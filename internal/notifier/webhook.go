@@ -0,0 +1,215 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhookTimeout bounds each post, so a dead chat hook cannot hold up the run
+const webhookTimeout = 30 * time.Second
+
+// WebhookChannel posts the summary to an HTTP endpoint: a Slack or Teams
+// incoming webhook, or any URL taking the JSON summary. Files cannot be
+// posted, so they are published and linked to.
+type WebhookChannel struct {
+	name      string
+	kind      string // ChannelSlack, ChannelTeams or ChannelWebhook
+	url       string
+	headers   map[string]string
+	publisher *Publisher // nil lists the files without links
+	logger    *zap.Logger
+	client    *http.Client
+}
+
+// NewWebhookChannel posts to url in the payload format of kind
+func NewWebhookChannel(logger *zap.Logger, name, kind, url string, headers map[string]string, publisher *Publisher) *WebhookChannel {
+	return &WebhookChannel{
+		name:      name,
+		kind:      kind,
+		url:       url,
+		headers:   headers,
+		publisher: publisher,
+		logger:    logger,
+		client:    &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Name returns the channel name from the channels file
+func (w *WebhookChannel) Name() string {
+	return w.name
+}
+
+// Send publishes the files and posts the message
+func (w *WebhookChannel) Send(msg Message) error {
+	links := w.publish(msg.Files)
+
+	var payload any
+	switch w.kind {
+	case ChannelSlack:
+		payload = map[string]string{"text": chatText(msg, slackFormat, links)}
+	case ChannelTeams:
+		payload = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  msg.Subject,
+			"text":     chatText(msg, teamsFormat, links),
+		}
+	default:
+		payload = newWebhookPayload(msg, links)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", w.kind, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", w.kind, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", w.kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", w.kind, resp.Status, strings.TrimSpace(string(reply)))
+	}
+
+	w.logger.Info("Posted to webhook", zap.String("channel", w.name), zap.String("type", w.kind))
+	return nil
+}
+
+// publish puts the files online and returns the links of the ones that were
+// published. A file that fails is listed without a link; the message still goes.
+func (w *WebhookChannel) publish(files []string) map[string]string {
+	links := map[string]string{}
+	if len(files) == 0 {
+		return links
+	}
+	if w.publisher == nil {
+		w.logger.Warn("No ATTACHMENT_PUBLISH_DIR or ATTACHMENT_UPLOAD_URL, posting the report files without links", zap.String("channel", w.name))
+		return links
+	}
+
+	for _, file := range files {
+		link, err := w.publisher.Publish(file)
+		if err != nil {
+			w.logger.Error("Failed to publish report file, posting it without a link", zap.String("channel", w.name), zap.Error(err))
+			continue
+		}
+		links[file] = link
+	}
+	return links
+}
+
+// chatFormat is the markup of one chat app
+type chatFormat struct {
+	bold    func(text string) string
+	link    func(text, url string) string
+	newline string
+}
+
+var (
+	slackFormat = chatFormat{
+		bold:    func(text string) string { return "*" + text + "*" },
+		link:    func(text, url string) string { return "<" + url + "|" + text + ">" },
+		newline: "\n",
+	}
+	// Teams cards join single line breaks, so lines are separated by blank ones
+	teamsFormat = chatFormat{
+		bold:    func(text string) string { return "**" + text + "**" },
+		link:    func(text, url string) string { return "[" + text + "](" + url + ")" },
+		newline: "\n\n",
+	}
+)
+
+// chatText is the short chat version of a message: the headline numbers and
+// big movers instead of the full email. links holds the published files.
+func chatText(msg Message, format chatFormat, links map[string]string) string {
+	lines := []string{format.bold(msg.Subject)}
+
+	if msg.Report == nil {
+		lines = append(lines, msg.Text)
+		return strings.Join(lines, format.newline)
+	}
+
+	r := msg.Report
+	lines = append(lines, r.Notices...)
+	lines = append(lines, fmt.Sprintf("%s to %s", r.From, r.To))
+	for _, figure := range r.Figures {
+		lines = append(lines, fmt.Sprintf("%s: %s", figure.Label, format.bold(figure.Value)))
+	}
+
+	for _, movers := range r.Movers {
+		lines = append(lines, format.bold(movers.Title))
+		for _, line := range movers.Lines {
+			lines = append(lines, "- "+line)
+		}
+	}
+
+	for _, file := range msg.Files {
+		name := filepath.Base(file)
+		if url := links[file]; url != "" {
+			name = format.link(name, url)
+		}
+		lines = append(lines, "File: "+name)
+	}
+
+	return strings.Join(lines, format.newline)
+}
+
+// webhookPayload is the JSON posted to generic webhooks
+type webhookPayload struct {
+	Subject    string        `json:"subject"`
+	Text       string        `json:"text"`
+	From       string        `json:"from,omitempty"`
+	To         string        `json:"to,omitempty"`
+	Notices    []string      `json:"notices,omitempty"`
+	Figures    []webhookItem `json:"figures,omitempty"`
+	Comparison *Table        `json:"comparison,omitempty"`
+	Movers     []MoverList   `json:"movers,omitempty"`
+	Files      []webhookItem `json:"files"`
+}
+
+// webhookItem is a labelled value or a file and its link
+type webhookItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	Link  string `json:"link,omitempty"`
+}
+
+func newWebhookPayload(msg Message, links map[string]string) webhookPayload {
+	payload := webhookPayload{Subject: msg.Subject, Text: msg.Text, Files: []webhookItem{}}
+
+	if r := msg.Report; r != nil {
+		payload.From, payload.To = r.From, r.To
+		payload.Notices = r.Notices
+		payload.Comparison = r.Comparison
+		payload.Movers = r.Movers
+		for _, figure := range r.Figures {
+			payload.Figures = append(payload.Figures, webhookItem{Name: figure.Label, Value: figure.Value})
+		}
+	}
+
+	for _, file := range msg.Files {
+		payload.Files = append(payload.Files, webhookItem{Name: filepath.Base(file), Link: links[file]})
+	}
+
+	return payload
+}
@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+)
+
+func TestWebhookFileLinks(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.xlsx")
+	if err := os.WriteFile(report, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		kind string
+		cfg  *config.Config // nil has no publisher
+
+		wantLink string // in the posted body; empty wants no link at all
+	}{
+		{name: "webhook without a publisher", kind: ChannelWebhook},
+		{name: "slack without a publisher", kind: ChannelSlack},
+		{
+			name:     "webhook with a publish dir",
+			kind:     ChannelWebhook,
+			cfg:      &config.Config{AttachmentPublishDir: filepath.Join(dir, "published"), AttachmentLinkBase: "https://reports.example.com/detrack"},
+			wantLink: "https://reports.example.com/detrack/report.xlsx",
+		},
+		{
+			name:     "slack with a publish dir",
+			kind:     ChannelSlack,
+			cfg:      &config.Config{AttachmentPublishDir: filepath.Join(dir, "published"), AttachmentLinkBase: "https://reports.example.com/detrack"},
+			wantLink: "<https://reports.example.com/detrack/report.xlsx|report.xlsx>",
+		},
+		{
+			name: "teams with a failing upload",
+			kind: ChannelTeams,
+			cfg:  &config.Config{AttachmentUploadURL: "http://127.0.0.1:1/files"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				var payload any
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Errorf("posted body is not JSON: %v", err)
+				}
+				posted = string(body)
+			}))
+			defer srv.Close()
+
+			var publisher *Publisher
+			if tt.cfg != nil {
+				publisher = NewPublisher(zap.NewNop(), tt.cfg)
+			}
+			channel := NewWebhookChannel(zap.NewNop(), "hook", tt.kind, srv.URL, nil, publisher)
+			if err := channel.Send(Message{Subject: "Report", Text: "Totals", Report: &ReportEmail{From: "2026-02-01", To: "2026-02-28"}, Files: []string{report}}); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			if !strings.Contains(posted, "report.xlsx") {
+				t.Errorf("posted body does not name the file: %s", posted)
+			}
+			if tt.wantLink == "" {
+				if strings.Contains(posted, "/report.xlsx") {
+					t.Errorf("posted body links to a file that was not published: %s", posted)
+				}
+				return
+			}
+			// Slack links are escaped in the JSON body
+			if want, _ := json.Marshal(tt.wantLink); !strings.Contains(posted, strings.Trim(string(want), `"`)) {
+				t.Errorf("posted body does not link to %s: %s", tt.wantLink, posted)
+			}
+		})
+	}
+}
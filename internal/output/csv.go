@@ -0,0 +1,97 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+)
+
+// CSVWriter writes basePath_jobs.csv plus one basePath_<section>.csv per
+// summary and included section, e.g. basePath_report.csv and
+// basePath_unmapped_runs.csv
+type CSVWriter struct {
+	basePath string
+	columns  JobColumns
+	jobsFile *os.File
+	jobs     *csv.Writer
+}
+
+// NewCSVWriter opens the jobs file and writes its header
+func NewCSVWriter(basePath string, columns JobColumns) (*CSVWriter, error) {
+	file, err := os.Create(basePath + "_jobs.csv")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &CSVWriter{
+		basePath: basePath,
+		columns:  columns,
+		jobsFile: file,
+		jobs:     csv.NewWriter(file),
+	}
+
+	if err := w.jobs.Write(columns.Headers()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write jobs header: %w", err)
+	}
+
+	return w, nil
+}
+
+// WriteJob appends a row to the jobs file
+func (w *CSVWriter) WriteJob(job api.Job) error {
+	return w.jobs.Write(w.columns.Record(job))
+}
+
+// Finish closes the jobs file and writes the summary and section files
+func (w *CSVWriter) Finish(doc *Document) ([]string, error) {
+	w.jobs.Flush()
+	if err := w.jobs.Error(); err != nil {
+		w.jobsFile.Close()
+		return nil, fmt.Errorf("failed to write jobs CSV: %w", err)
+	}
+	if err := w.jobsFile.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close jobs CSV: %w", err)
+	}
+
+	paths := []string{w.jobsFile.Name()}
+	if !doc.Includes(jobSheet) {
+		if err := os.Remove(w.jobsFile.Name()); err != nil {
+			return nil, fmt.Errorf("failed to remove jobs CSV: %w", err)
+		}
+		paths = nil
+	}
+	for _, t := range sections(doc) {
+		path := w.basePath + "_" + strings.ToLower(strings.ReplaceAll(t.name, " ", "_")) + ".csv"
+		if err := writeCSVTable(path, t); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+func writeCSVTable(path string, t table) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write(t.headers)
+	for _, row := range t.rows {
+		writer.Write(textRow(row))
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return file.Close()
+}
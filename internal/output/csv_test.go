@@ -0,0 +1,117 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/processor"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+func TestCSVWriterSections(t *testing.T) {
+	loc := time.FixedZone("AEST", 10*60*60)
+	p, err := period.Resolve(period.Month, time.Date(2026, 3, 3, 0, 0, 0, 0, loc), "", "", loc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	jobs := []api.Job{
+		{ID: "good", Status: "completed", Date: "2026-02-10", Type: "Delivery", JobPrice: "1.00", RunNumber: "NORTH"},
+		{ID: "bad", Status: "completed", Date: "2026-02-10", Type: "Delivery", JobPrice: "abc", RunNumber: "NORTH"},
+		{ID: "late", Status: "completed", Date: "2026-03-01", Type: "Delivery", JobPrice: "1.00", RunNumber: "NORTH"},
+	}
+	rpt, err := report.Aggregate(zap.NewNop(), jobs, p, report.Options{Status: "completed", PricePolicy: config.PriceQuarantine})
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sheets    []string
+		wantFiles []string
+	}{
+		{
+			name:      "finance sections only",
+			sheets:    []string{"Quarantine", "Excluded"},
+			wantFiles: []string{"r_excluded.csv", "r_quarantine.csv", "r_report.csv"},
+		},
+		{
+			name:   "every section",
+			sheets: nil,
+			wantFiles: []string{
+				"r_excluded.csv", "r_jobs.csv", "r_quarantine.csv", "r_report.csv",
+				"r_unassigned_jobs.csv", "r_unmapped_runs.csv",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			columns, err := NewJobColumns(DefaultJobColumns)
+			if err != nil {
+				t.Fatalf("NewJobColumns: %v", err)
+			}
+
+			w, err := NewCSVWriter(filepath.Join(dir, "r"), columns)
+			if err != nil {
+				t.Fatalf("NewCSVWriter: %v", err)
+			}
+			for _, job := range jobs {
+				if err := w.WriteJob(job); err != nil {
+					t.Fatalf("WriteJob: %v", err)
+				}
+			}
+
+			doc := &Document{
+				Period:     p,
+				Summaries:  []Summary{{Name: "Report", Metrics: report.Metrics, Report: rpt}},
+				Unmapped:   []processor.UnmappedRun{{Raw: "N0RTH", Value: "N0RTH", Jobs: 1}},
+				Unassigned: []processor.UnassignedJob{{ID: "none", ReportedAs: "UNASSIGNED"}},
+				Sheets:     tt.sheets,
+			}
+			paths, err := w.Finish(doc)
+			if err != nil {
+				t.Fatalf("Finish: %v", err)
+			}
+
+			var files []string
+			for _, path := range paths {
+				files = append(files, filepath.Base(path))
+			}
+			slices.Sort(files)
+			if !slices.Equal(files, tt.wantFiles) {
+				t.Errorf("Finish returned %q, want %q", files, tt.wantFiles)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var onDisk []string
+			for _, entry := range entries {
+				onDisk = append(onDisk, entry.Name())
+			}
+			if !slices.Equal(onDisk, tt.wantFiles) {
+				t.Errorf("files on disk = %q, want %q", onDisk, tt.wantFiles)
+			}
+
+			quarantine, err := os.ReadFile(filepath.Join(dir, "r_quarantine.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(quarantine), "bad,") {
+				t.Errorf("quarantine CSV does not list the bad job:\n%s", quarantine)
+			}
+		})
+	}
+}
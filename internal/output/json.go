@@ -0,0 +1,169 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+// JSONWriter writes a single JSON document. Jobs are streamed into the
+// "jobs" array; the other sections follow it.
+type JSONWriter struct {
+	path  string
+	file  *os.File
+	buf   *bufio.Writer
+	count int
+}
+
+// NewJSONWriter opens the file and starts the jobs array
+func NewJSONWriter(path string) (*JSONWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &JSONWriter{path: path, file: file, buf: bufio.NewWriter(file)}
+	w.buf.WriteString(`{"jobs":[`)
+
+	return w, nil
+}
+
+// WriteJob appends a job to the jobs array
+func (w *JSONWriter) WriteJob(job api.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job %s: %w", job.ID, err)
+	}
+
+	if w.count > 0 {
+		w.buf.WriteByte(',')
+	}
+	w.count++
+
+	_, err = w.buf.Write(data)
+	return err
+}
+
+// Finish writes the remaining sections and closes the file
+func (w *JSONWriter) Finish(doc *Document) ([]string, error) {
+	defer w.file.Close()
+
+	sections := struct {
+		Period     periodJSON    `json:"period"`
+		Reports    []summaryJSON `json:"reports"`
+		Unmapped   any           `json:"unmapped_runs,omitempty"`
+		Unassigned any           `json:"unassigned_jobs,omitempty"`
+	}{
+		Period:  newPeriodJSON(doc.Period),
+		Reports: newSummariesJSON(doc.Summaries),
+	}
+	// Left out sections are dropped; included ones are shown even when empty
+	if doc.Includes("Unmapped Runs") {
+		sections.Unmapped = doc.Unmapped
+	}
+	if doc.Includes("Unassigned Jobs") {
+		sections.Unassigned = doc.Unassigned
+	}
+
+	rest, err := json.Marshal(sections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	// Splice the sections into the open object: `],` + `"period":...}`
+	w.buf.WriteString("],")
+	w.buf.Write(rest[1:])
+	w.buf.WriteByte('\n')
+
+	if err := w.buf.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+
+	return []string{w.path}, nil
+}
+
+// summaryJSON is a summary's name next to its report fields
+type summaryJSON struct {
+	Name    string   `json:"name"`
+	Metrics []string `json:"metrics"`
+	*report.Report
+	Comparisons []comparisonJSON `json:"comparisons,omitempty"`
+}
+
+// comparisonJSON lists every row, then the totals, against one earlier period.
+// Rows that had jobs in that period and none now are listed with zeros.
+type comparisonJSON struct {
+	Name   string            `json:"name"`
+	Period periodJSON        `json:"period"`
+	Rows   []comparedRowJSON `json:"rows"`
+}
+
+type comparedRowJSON struct {
+	Keys     []string             `json:"keys"`
+	Measures map[string]deltaJSON `json:"measures"`
+}
+
+type deltaJSON struct {
+	Before    any      `json:"before"`
+	Change    any      `json:"change"`
+	ChangePct *float64 `json:"change_pct"` // null when before is 0
+}
+
+func newComparisonJSON(r *report.Report, baseline report.Baseline) comparisonJSON {
+	c := comparisonJSON{Name: baseline.Name, Period: newPeriodJSON(baseline.Period)}
+
+	for _, row := range report.ComparedRows(r, []report.Baseline{baseline}) {
+		compared := comparedRowJSON{Keys: row.Keys, Measures: make(map[string]deltaJSON, len(report.ComparedMeasures))}
+		for _, measure := range report.ComparedMeasures {
+			delta := baseline.Delta(row, measure)
+
+			d := deltaJSON{Before: delta.Before, Change: delta.Change()}
+			if measure.Money {
+				d = deltaJSON{Before: money.Cents(delta.Before), Change: money.Cents(delta.Change())}
+			}
+			if percent, ok := delta.Percent(); ok {
+				d.ChangePct = &percent
+			}
+			compared.Measures[measure.Name] = d
+		}
+		c.Rows = append(c.Rows, compared)
+	}
+
+	return c
+}
+
+func newSummariesJSON(summaries []Summary) []summaryJSON {
+	reports := make([]summaryJSON, len(summaries))
+	for i, summary := range summaries {
+		metrics := make([]string, len(summary.Metrics))
+		for j, metric := range summary.Metrics {
+			metrics[j] = metric.Name
+		}
+		reports[i] = summaryJSON{Name: summary.Name, Metrics: metrics, Report: summary.Report}
+		for _, baseline := range summary.Baselines {
+			reports[i].Comparisons = append(reports[i].Comparisons, newComparisonJSON(summary.Report, baseline))
+		}
+	}
+	return reports
+}
+
+// periodJSON shows the period with an inclusive last day
+type periodJSON struct {
+	Kind string `json:"kind"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func newPeriodJSON(p period.Period) periodJSON {
+	return periodJSON{
+		Kind: string(p.Kind),
+		From: p.From.Format(period.DateLayout),
+		To:   p.LastDay().Format(period.DateLayout),
+	}
+}
@@ -0,0 +1,239 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+)
+
+// Page layout: A4 landscape, monospaced so columns line up when printed
+const (
+	pdfPageWidth  = 842
+	pdfPageHeight = 595
+	pdfMargin     = 36
+	pdfFontSize   = 7
+	pdfLeading    = 9
+
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	pdfCharsPerLine = (pdfPageWidth - 2*pdfMargin) * 10 / (6 * pdfFontSize) // Courier glyphs are 0.6em wide
+	pdfMaxCellWidth = 40
+)
+
+// Fixed object ids; pages and their contents are numbered from pdfFirstFreeID
+const (
+	pdfCatalogID = 1
+	pdfPagesID   = 2
+	pdfFontID    = 3
+
+	pdfFirstFreeID = 4
+)
+
+// PDFWriter writes a printable, plain text PDF: the report sections first,
+// then the full Jobs listing. Job pages are written as soon as they fill up
+// and only listed after the report pages in the page tree.
+type PDFWriter struct {
+	path     string
+	columns  JobColumns
+	widths   []int // fixed Jobs column widths, so pages can be written as jobs arrive
+	file     *os.File
+	out      *bufio.Writer
+	written  int64
+	offsets  map[int]int64
+	nextID   int
+	jobPages []int
+	lines    []string
+}
+
+// NewPDFWriter opens the file and writes the PDF header
+func NewPDFWriter(path string, columns JobColumns) (*PDFWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &PDFWriter{
+		path:    path,
+		columns: columns,
+		widths:  columns.widths(),
+		file:    file,
+		out:     bufio.NewWriter(file),
+		offsets: make(map[int]int64),
+		nextID:  pdfFirstFreeID,
+	}
+	w.printf("%%PDF-1.4\n")
+
+	return w, nil
+}
+
+// WriteJob appends a line to the Jobs listing, flushing a page when full
+func (w *PDFWriter) WriteJob(job api.Job) error {
+	if len(w.lines) == 0 {
+		w.lines = append(w.lines,
+			"Jobs",
+			formatLine(w.columns.Headers(), w.widths),
+			strings.Repeat("-", pdfCharsPerLine),
+		)
+	}
+
+	w.lines = append(w.lines, formatLine(w.columns.Record(job), w.widths))
+	if len(w.lines) == pdfLinesPerPage {
+		w.jobPages = append(w.jobPages, w.writePage(w.lines))
+		w.lines = nil
+	}
+
+	return nil
+}
+
+// Finish writes the report pages, the page tree and the trailer
+func (w *PDFWriter) Finish(doc *Document) ([]string, error) {
+	defer w.file.Close()
+
+	if len(w.lines) > 0 {
+		w.jobPages = append(w.jobPages, w.writePage(w.lines))
+		w.lines = nil
+	}
+
+	lines := []string{
+		"WCP Detrack Report " + doc.Period.String(),
+		"",
+	}
+	for _, t := range sections(doc) {
+		lines = append(lines, tableLines(t)...)
+		lines = append(lines, "")
+	}
+
+	var pages []int
+	for start := 0; start < len(lines); start += pdfLinesPerPage {
+		end := min(start+pdfLinesPerPage, len(lines))
+		pages = append(pages, w.writePage(lines[start:end]))
+	}
+	pages = append(pages, w.jobPages...)
+
+	// Font, page tree and catalog
+	w.beginObject(pdfFontID)
+	w.printf("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>\nendobj\n")
+
+	kids := make([]string, len(pages))
+	for i, id := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	w.beginObject(pdfPagesID)
+	w.printf("<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(pages))
+
+	w.beginObject(pdfCatalogID)
+	w.printf("<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pdfPagesID)
+
+	// Cross-reference table
+	xref := w.written
+	w.printf("xref\n0 %d\n0000000000 65535 f \n", w.nextID)
+	for id := 1; id < w.nextID; id++ {
+		w.printf("%010d 00000 n \n", w.offsets[id])
+	}
+	w.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", w.nextID, pdfCatalogID, xref)
+
+	if err := w.out.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+
+	return []string{w.path}, nil
+}
+
+// writePage writes a page and its content stream, returning the page id
+func (w *PDFWriter) writePage(lines []string) int {
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDF(line))
+	}
+	content.WriteString("ET\n")
+
+	contentID := w.allocate()
+	w.beginObject(contentID)
+	w.printf("<< /Length %d >>\nstream\n%sendstream\nendobj\n", content.Len(), content.String())
+
+	pageID := w.allocate()
+	w.beginObject(pageID)
+	w.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+		pdfPagesID, pdfPageWidth, pdfPageHeight, pdfFontID, contentID)
+
+	return pageID
+}
+
+func (w *PDFWriter) allocate() int {
+	id := w.nextID
+	w.nextID++
+	return id
+}
+
+func (w *PDFWriter) beginObject(id int) {
+	w.offsets[id] = w.written
+	w.printf("%d 0 obj\n", id)
+}
+
+func (w *PDFWriter) printf(format string, args ...any) {
+	n, _ := fmt.Fprintf(w.out, format, args...)
+	w.written += int64(n)
+}
+
+// tableLines renders a section as a title, header and padded rows
+func tableLines(t table) []string {
+	rows := make([][]string, len(t.rows))
+	widths := make([]int, len(t.headers))
+	for i, h := range t.headers {
+		widths[i] = len(h)
+	}
+	for i, row := range t.rows {
+		rows[i] = textRow(row)
+		for j, cell := range rows[i] {
+			widths[j] = min(max(widths[j], len(cell)), pdfMaxCellWidth)
+		}
+	}
+
+	lines := []string{t.name, formatLine(t.headers, widths), strings.Repeat("-", pdfCharsPerLine)}
+	for _, row := range rows {
+		lines = append(lines, formatLine(row, widths))
+	}
+	if len(rows) == 0 {
+		lines = append(lines, "(none)")
+	}
+
+	return lines
+}
+
+// formatLine pads or cuts each cell to its width
+func formatLine(cells []string, widths []int) string {
+	var line strings.Builder
+	for i, cell := range cells {
+		width := widths[i]
+		if len(cell) > width {
+			cell = cell[:width-1] + "~"
+		}
+		fmt.Fprintf(&line, "%-*s ", width, cell)
+	}
+
+	text := strings.TrimRight(line.String(), " ")
+	if len(text) > pdfCharsPerLine {
+		text = text[:pdfCharsPerLine]
+	}
+	return text
+}
+
+// escapePDF escapes a string literal; the standard fonts only cover ASCII here
+func escapePDF(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r < 32 || r > 126:
+			escaped.WriteByte('?')
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
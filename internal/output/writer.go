@@ -0,0 +1,443 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/processor"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+// Supported output formats
+const (
+	FormatXLSX = "xlsx"
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatPDF  = "pdf"
+)
+
+// Document is everything written after the jobs
+type Document struct {
+	Period period.Period
+	// Summaries are the aggregated sheets, e.g. Report and Drivers; there is at least one
+	Summaries  []Summary
+	Unmapped   []processor.UnmappedRun
+	Unassigned []processor.UnassignedJob
+	// Sheets are the fixed sections to write, e.g. Jobs and Excluded; nil writes them all
+	Sheets []string
+}
+
+// Includes reports whether the fixed section name is written
+func (d *Document) Includes(name string) bool {
+	if d.Sheets == nil {
+		return true
+	}
+	for _, sheet := range d.Sheets {
+		if strings.EqualFold(sheet, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary is one aggregated sheet and the metrics it shows
+type Summary struct {
+	Name    string
+	Metrics []report.Metric
+	Report  *report.Report
+	// Baselines are the earlier periods shown next to each row, if any
+	Baselines []report.Baseline
+}
+
+// Primary returns the first summary's report. Every summary counts the
+// same jobs, so any has the exclusions and price issues.
+func (d *Document) Primary() *report.Report {
+	return d.Summaries[0].Report
+}
+
+// fixedSheets are the sections every output has besides the summaries
+var fixedSheets = []string{jobSheet, "Quarantine", "Unmapped Runs", "Unassigned Jobs", "Excluded"}
+
+// CheckSummaryNames fails on names that cannot all be sheets of one workbook
+func CheckSummaryNames(names []string) error {
+	used := make(map[string]bool)
+	for _, name := range fixedSheets {
+		used[strings.ToLower(name)] = true
+	}
+
+	for _, name := range names {
+		switch {
+		case len(name) > 31:
+			return fmt.Errorf("sheet name %q is longer than 31 characters", name)
+		case strings.ContainsAny(name, `[]:*?/\`):
+			return fmt.Errorf("sheet name %q cannot contain []:*?/\\", name)
+		case used[strings.ToLower(name)]:
+			return fmt.Errorf("sheet name %q is already taken", name)
+		}
+		used[strings.ToLower(name)] = true
+	}
+
+	return nil
+}
+
+// CheckSheets fails on sheets that are neither a fixed section nor a summary,
+// or a selection without any summary
+func CheckSheets(sheets, summaryNames []string) error {
+	summaries := 0
+	for _, sheet := range sheets {
+		switch {
+		case containsFold(summaryNames, sheet):
+			summaries++
+		case !containsFold(fixedSheets, sheet):
+			return fmt.Errorf("unknown sheet %q (want one of %s, or a pivot)", sheet, strings.Join(fixedSheets, ", "))
+		}
+	}
+	if len(sheets) > 0 && summaries == 0 {
+		return errors.New("sheets must include at least one summary, e.g. Report")
+	}
+
+	return nil
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// summaries returns the aggregated tables, in order
+func (d *Document) summaries() []table {
+	tables := make([]table, len(d.Summaries))
+	for i, summary := range d.Summaries {
+		tables[i] = summaryTable(summary)
+	}
+	return tables
+}
+
+// ReportWriter renders the Jobs and Report data in one format.
+// Jobs are written one at a time so writers never need the full list.
+type ReportWriter interface {
+	// WriteJob appends a job to the Jobs section
+	WriteJob(job api.Job) error
+	// Finish writes the report sections, closes the output and returns the files produced
+	Finish(doc *Document) ([]string, error)
+}
+
+// NewWriters creates one writer per format, each writing dir/baseName.<ext>
+// with columns in the Jobs section
+func NewWriters(formats []string, dir, baseName string, columns JobColumns) ([]ReportWriter, error) {
+	writers := make([]ReportWriter, 0, len(formats))
+	basePath := filepath.Join(dir, baseName)
+
+	for _, format := range formats {
+		var (
+			w   ReportWriter
+			err error
+		)
+
+		switch format {
+		case FormatXLSX:
+			w, err = NewXLSXWriter(basePath+".xlsx", columns)
+		case FormatCSV:
+			w, err = NewCSVWriter(basePath, columns)
+		case FormatJSON:
+			w, err = NewJSONWriter(basePath + ".json")
+		case FormatPDF:
+			w, err = NewPDFWriter(basePath+".pdf", columns)
+		default:
+			err = fmt.Errorf("unknown output format %q", format)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s writer: %w", format, err)
+		}
+		writers = append(writers, w)
+	}
+
+	return writers, nil
+}
+
+// jobColumn is one column of the Jobs section
+type jobColumn struct {
+	header string
+	width  int // characters in the PDF listing
+	value  func(job api.Job) any
+}
+
+// allJobColumns lists every column the Jobs section can show, by Detrack field name
+var allJobColumns = []jobColumn{
+	{"id", 24, func(j api.Job) any { return j.ID }},
+	{"status", 10, func(j api.Job) any { return j.Status }},
+	{"date", 10, func(j api.Job) any { return j.Date }},
+	{"type", 10, func(j api.Job) any { return j.Type }},
+	{"items_count", 5, func(j api.Job) any { return j.ItemCount }},
+	{"job_price", 12, jobPrice},
+	{"total_price", 12, totalPrice},
+	{"invoice_amount", 12, func(j api.Job) any { return rawAmount(j.RawInvoiceAmount) }},
+	{"payment_amount", 12, func(j api.Job) any { return rawAmount(j.RawPaymentAmount) }},
+	{"invoice_number", 14, func(j api.Job) any { return j.InvoiceNumber }},
+	{"do_number", 20, func(j api.Job) any { return j.DoNumber }},
+	{"run_number", 24, func(j api.Job) any { return j.RunNumber }},
+	{"time_window", 13, func(j api.Job) any { return j.TimeWindow }},
+	{"assign_to", 16, func(j api.Job) any { return j.AssignTo }},
+	{"deliver_to_collect_from", 20, func(j api.Job) any { return j.Customer }},
+	{"address", 30, func(j api.Job) any { return j.Address }},
+	{"pod_time", 25, func(j api.Job) any { return j.PodTime }},
+	{"received_by_sent_by", 16, func(j api.Job) any { return j.ReceivedBy }},
+	{"reason", 20, func(j api.Job) any { return j.Reason }},
+}
+
+// DefaultJobColumns is the Jobs section when JOBS_COLUMNS is not set
+var DefaultJobColumns = []string{"id", "status", "date", "type", "items_count", "job_price", "do_number", "run_number"}
+
+// JobColumns is the ordered set of columns written to the Jobs section
+type JobColumns []jobColumn
+
+// NewJobColumns picks the named columns, in order. The columns the
+// reconciliation reads back must be included.
+func NewJobColumns(names []string) (JobColumns, error) {
+	byName := make(map[string]jobColumn, len(allJobColumns))
+	for _, column := range allJobColumns {
+		byName[column.header] = column
+	}
+
+	columns := make(JobColumns, 0, len(names))
+	chosen := make(map[string]bool, len(names))
+	for _, name := range names {
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown job column %q", name)
+		}
+		if chosen[name] {
+			return nil, fmt.Errorf("job column %q listed twice", name)
+		}
+		chosen[name] = true
+		columns = append(columns, column)
+	}
+
+	for _, name := range report.ReconcileColumns {
+		if !chosen[name] {
+			return nil, fmt.Errorf("job column %q is required to reconcile the report", name)
+		}
+	}
+
+	return columns, nil
+}
+
+// JobColumnNames lists every column NewJobColumns accepts
+func JobColumnNames() []string {
+	names := make([]string, len(allJobColumns))
+	for i, column := range allJobColumns {
+		names[i] = column.header
+	}
+	return names
+}
+
+// jobPrice keeps unparseable prices as text so they stay visible
+func jobPrice(job api.Job) any {
+	if price, err := job.Price(); err == nil {
+		return price
+	}
+	return job.JobPrice
+}
+
+// totalPrice shows total_price like jobPrice shows job_price
+func totalPrice(job api.Job) any {
+	if price, err := job.TotalPriceAmount(); err == nil {
+		return price
+	}
+	return job.TotalPrice
+}
+
+// rawAmount shows invoice and payment amounts like jobPrice shows job_price
+func rawAmount(amount api.RawAmount) any {
+	if value, err := amount.Cents(); err == nil {
+		return value
+	}
+	return string(amount)
+}
+
+// Headers returns the Jobs section headers
+func (c JobColumns) Headers() []string {
+	headers := make([]string, len(c))
+	for i, column := range c {
+		headers[i] = column.header
+	}
+	return headers
+}
+
+// values returns the typed cells of a job row
+func (c JobColumns) values(job api.Job) []any {
+	values := make([]any, len(c))
+	for i, column := range c {
+		values[i] = column.value(job)
+	}
+	return values
+}
+
+// Record returns a job row as text, the way CSV and PDF show it
+func (c JobColumns) Record(job api.Job) []string {
+	return textRow(c.values(job))
+}
+
+// widths returns the PDF column widths
+func (c JobColumns) widths() []int {
+	widths := make([]int, len(c))
+	for i, column := range c {
+		widths[i] = column.width
+	}
+	return widths
+}
+
+// table is a report section with typed cells
+type table struct {
+	name    string
+	headers []string
+	rows    [][]any
+}
+
+// sections returns every section written after the jobs, Report first
+func sections(doc *Document) []table {
+	tables := doc.summaries()
+	for _, t := range []table{
+		quarantineTable(doc.Primary()),
+		unmappedTable(doc.Unmapped),
+		unassignedTable(doc.Unassigned),
+		excludedTable(doc.Primary()),
+	} {
+		if doc.Includes(t.name) {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// summaryTable shows one key column per dimension, then the metrics
+func summaryTable(summary Summary) table {
+	r := summary.Report
+
+	t := table{name: summary.Name, headers: append([]string{}, r.GroupBy...)}
+	for _, metric := range summary.Metrics {
+		t.headers = append(t.headers, metric.Name)
+	}
+	for _, baseline := range summary.Baselines {
+		for _, measure := range report.ComparedMeasures {
+			column := measure.Name + "_" + baseline.Name
+			t.headers = append(t.headers, column, column+"_change", column+"_change_pct")
+		}
+	}
+
+	// Rows that had jobs in an earlier period and none now are shown with zeros
+	for _, row := range report.ComparedRows(r, summary.Baselines) {
+		cells := make([]any, 0, len(t.headers))
+		for _, key := range row.Keys {
+			cells = append(cells, key)
+		}
+		for _, metric := range summary.Metrics {
+			cells = append(cells, metric.Value(row))
+		}
+		for _, baseline := range summary.Baselines {
+			for _, measure := range report.ComparedMeasures {
+				cells = append(cells, deltaCells(measure, baseline.Delta(row, measure))...)
+			}
+		}
+		t.rows = append(t.rows, cells)
+	}
+
+	return t
+}
+
+// deltaCells shows the baseline value, the change and the change in percent
+func deltaCells(measure report.Measure, delta report.Delta) []any {
+	percent := ""
+	if p, ok := delta.Percent(); ok {
+		percent = strconv.FormatFloat(p, 'f', 1, 64) + "%"
+	}
+
+	if measure.Money {
+		return []any{money.Cents(delta.Before), money.Cents(delta.Change()), percent}
+	}
+	return []any{delta.Before, delta.Change(), percent}
+}
+
+// quarantineTable lists jobs with an amount that could not be parsed
+func quarantineTable(r *report.Report) table {
+	t := table{
+		name:    "Quarantine",
+		headers: []string{"id", "do_number", "date", "run_number", "field", "raw_value", "action"},
+	}
+
+	for _, issue := range r.PriceIssues {
+		t.rows = append(t.rows, []any{issue.JobID, issue.DoNumber, issue.Date, issue.RunNumber, issue.Field, issue.RawValue, issue.Action})
+	}
+
+	return t
+}
+
+// unmappedTable lists raw run numbers dispatch should clean up
+func unmappedTable(runs []processor.UnmappedRun) table {
+	t := table{
+		name:    "Unmapped Runs",
+		headers: []string{"raw_run_number", "reported_as", "job_count", "example_do_numbers"},
+	}
+
+	for _, run := range runs {
+		t.rows = append(t.rows, []any{run.Raw, run.Value, run.Jobs, strings.Join(run.Examples, ", ")})
+	}
+
+	return t
+}
+
+// unassignedTable lists jobs that had no run number in Detrack
+func unassignedTable(jobs []processor.UnassignedJob) table {
+	t := table{
+		name:    "Unassigned Jobs",
+		headers: []string{"id", "do_number", "date", "type", "time_window", "reported_as", "inference_rule"},
+	}
+
+	for _, job := range jobs {
+		t.rows = append(t.rows, []any{job.ID, job.DoNumber, job.Date, job.Type, job.TimeWindow, job.ReportedAs, job.Rule})
+	}
+
+	return t
+}
+
+// excludedTable shows how many jobs were left out and why
+func excludedTable(r *report.Report) table {
+	t := table{
+		name:    "Excluded",
+		headers: []string{"reason", "count"},
+	}
+
+	for _, reason := range report.ExclusionReasons {
+		t.rows = append(t.rows, []any{string(reason), r.Excluded[reason]})
+	}
+	t.rows = append(t.rows, []any{"TOTAL", r.ExcludedTotal()})
+
+	return t
+}
+
+// textRow renders typed cells as text; money is a plain decimal
+func textRow(values []any) []string {
+	row := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case money.Cents:
+			row[i] = v.Decimal()
+		case float32:
+			row[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	return row
+}
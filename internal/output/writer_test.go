@@ -0,0 +1,73 @@
+package output
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+func TestComparisonListsGoneRows(t *testing.T) {
+	loc := time.FixedZone("AEST", 10*60*60)
+	p, err := period.Resolve(period.Month, time.Date(2026, 3, 3, 0, 0, 0, 0, loc), "", "", loc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	opts := report.Options{Status: "completed", PricePolicy: config.PriceReject}
+
+	current, err := report.Aggregate(zap.NewNop(), []api.Job{
+		{ID: "n1", Status: "completed", Date: "2026-02-10", Type: "Delivery", JobPrice: "5.00", RunNumber: "NORTH"},
+	}, p, opts)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	previous, err := report.Aggregate(zap.NewNop(), []api.Job{
+		{ID: "n0", Status: "completed", Date: "2026-01-10", Type: "Delivery", JobPrice: "4.00", RunNumber: "NORTH"},
+		{ID: "s0", Status: "completed", Date: "2026-01-10", Type: "Delivery", JobPrice: "8.00", RunNumber: "SOUTH"},
+	}, p.Previous(), opts)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+
+	baseline := report.NewBaseline(config.ComparePrevious, previous)
+	summary := Summary{Name: "Report", Metrics: report.Metrics, Report: current, Baselines: []report.Baseline{baseline}}
+
+	// Report sheet: run_number, the metrics, then before, change and percent per measure
+	sheet := summaryTable(summary)
+	var runs []any
+	for _, row := range sheet.rows {
+		runs = append(runs, row[0])
+	}
+	if !slices.Equal(runs, []any{"NORTH", "SOUTH", "TOTAL"}) {
+		t.Fatalf("Report sheet rows = %v, want NORTH, SOUTH and TOTAL", runs)
+	}
+	south := sheet.rows[1]
+	freight := slices.Index(sheet.headers, "freight_revenue")
+	if south[freight] != money.Cents(0) {
+		t.Errorf("SOUTH freight_revenue = %v, want 0", south[freight])
+	}
+	before := slices.Index(sheet.headers, "freight_revenue_previous")
+	if got := south[before : before+3]; !slices.Equal(got, []any{money.Cents(800), money.Cents(-800), "-100.0%"}) {
+		t.Errorf("SOUTH freight_revenue_previous columns = %v, want A$8.00, -A$8.00 and -100.0%%", got)
+	}
+
+	// JSON comparisons
+	comparison := newComparisonJSON(current, baseline)
+	var keys []string
+	for _, row := range comparison.Rows {
+		keys = append(keys, row.Keys[0])
+	}
+	if !slices.Equal(keys, []string{"NORTH", "SOUTH", "TOTAL"}) {
+		t.Fatalf("JSON comparison rows = %q, want NORTH, SOUTH and TOTAL", keys)
+	}
+	if d := comparison.Rows[1].Measures["freight_revenue"]; d.Before != money.Cents(800) || d.Change != money.Cents(-800) {
+		t.Errorf("SOUTH JSON freight_revenue = %+v, want before 800 and change -800", d)
+	}
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+)
+
+const jobSheet = "Jobs"
+
+// XLSXWriter writes one workbook with a sheet per section. Sheets are written
+// with excelize stream writers, which spill large sheets to a temp file
+// instead of keeping every cell in memory.
+type XLSXWriter struct {
+	path       string
+	columns    JobColumns
+	file       *excelize.File
+	jobs       *excelize.StreamWriter
+	moneyStyle int
+	nextRow    int
+}
+
+// NewXLSXWriter creates the workbook and starts streaming its Jobs sheet
+func NewXLSXWriter(path string, columns JobColumns) (*XLSXWriter, error) {
+	f := excelize.NewFile()
+
+	// Money cells are numbers formatted as AUD
+	moneyFormat := money.ExcelFormat
+	moneyStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &moneyFormat})
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to create money cell style: %w", err)
+	}
+
+	w := &XLSXWriter{
+		path:       path,
+		columns:    columns,
+		file:       f,
+		moneyStyle: moneyStyle,
+		nextRow:    2,
+	}
+
+	w.jobs, err = w.newSheet(jobSheet, columns.Headers())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// WriteJob appends a row to the Jobs sheet
+func (w *XLSXWriter) WriteJob(job api.Job) error {
+	if err := w.writeRow(w.jobs, w.nextRow, w.columns.values(job)); err != nil {
+		return fmt.Errorf("failed to write job %s: %w", job.ID, err)
+	}
+	w.nextRow++
+	return nil
+}
+
+// Finish adds the report sheets, saves the workbook and closes it
+func (w *XLSXWriter) Finish(doc *Document) ([]string, error) {
+	defer w.file.Close()
+
+	if err := w.jobs.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to flush '%s' sheet: %w", jobSheet, err)
+	}
+
+	tables := sections(doc)
+	for _, t := range tables {
+		sheet, err := w.newSheet(t.name, t.headers)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, row := range t.rows {
+			if err := w.writeRow(sheet, i+2, row); err != nil {
+				return nil, fmt.Errorf("failed to write '%s' sheet: %w", t.name, err)
+			}
+		}
+
+		if err := sheet.Flush(); err != nil {
+			return nil, fmt.Errorf("failed to flush '%s' sheet: %w", t.name, err)
+		}
+	}
+
+	// Delete default Sheet1 and open on the first summary (Report or Drivers)
+	w.file.DeleteSheet("Sheet1")
+	if !doc.Includes(jobSheet) {
+		w.file.DeleteSheet(jobSheet)
+	}
+	if index, err := w.file.GetSheetIndex(tables[0].name); err == nil && index >= 0 {
+		w.file.SetActiveSheet(index)
+	}
+
+	if err := w.file.SaveAs(w.path); err != nil {
+		return nil, fmt.Errorf("failed to save XLSX file: %w", err)
+	}
+
+	return []string{w.path}, nil
+}
+
+// newSheet adds a sheet and writes its header row
+func (w *XLSXWriter) newSheet(name string, headers []string) (*excelize.StreamWriter, error) {
+	if _, err := w.file.NewSheet(name); err != nil {
+		return nil, fmt.Errorf("failed to create '%s' sheet: %w", name, err)
+	}
+
+	sheet, err := w.file.NewStreamWriter(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream '%s' sheet: %w", name, err)
+	}
+
+	cells := make([]any, len(headers))
+	for i, h := range headers {
+		cells[i] = h
+	}
+	if err := sheet.SetRow("A1", cells); err != nil {
+		return nil, fmt.Errorf("failed to write '%s' headers: %w", name, err)
+	}
+
+	return sheet, nil
+}
+
+func (w *XLSXWriter) writeRow(sheet *excelize.StreamWriter, row int, values []any) error {
+	cells := make([]any, len(values))
+	for i, value := range values {
+		// Money cells are numbers formatted as AUD
+		if amount, ok := value.(money.Cents); ok {
+			cells[i] = excelize.Cell{StyleID: w.moneyStyle, Value: amount.Float64()}
+			continue
+		}
+		cells[i] = value
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, row)
+	if err != nil {
+		return err
+	}
+	return sheet.SetRow(cell, cells)
+}
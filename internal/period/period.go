@@ -0,0 +1,174 @@
+package period
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the layout used for all date flags and report labels
+const DateLayout = "2006-01-02"
+
+// Kind selects how the reporting range is resolved
+type Kind string
+
+const (
+	Auto    Kind = "auto"    // previous month during the first week, previous week otherwise
+	Week    Kind = "week"    // previous Monday → Sunday
+	Month   Kind = "month"   // previous calendar month
+	Quarter Kind = "quarter" // previous calendar quarter
+	Custom  Kind = "custom"  // explicit --from / --to
+)
+
+// Period is a resolved reporting range, half-open: [From, To)
+type Period struct {
+	Kind Kind
+	From time.Time // midnight starting the first day
+	To   time.Time // midnight after the last day, excluded
+}
+
+// ParseKind validates a --period flag value
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(strings.ToLower(strings.TrimSpace(s))); k {
+	case Auto, Week, Month, Quarter, Custom:
+		return k, nil
+	default:
+		return "", fmt.Errorf("unknown period %q (want auto, week, month, quarter or custom)", s)
+	}
+}
+
+// Resolve turns the CLI inputs into a concrete Period.
+// from/to are only valid for Custom; asOf is the day the report is generated on.
+func Resolve(kind Kind, asOf time.Time, from, to string, loc *time.Location) (Period, error) {
+	asOf = asOf.In(loc)
+
+	if kind != Custom && (from != "" || to != "") {
+		return Period{}, errors.New("--from/--to can only be used with --period=custom")
+	}
+
+	if kind == Auto {
+		// First Monday of the month → previous month
+		if asOf.Day() <= 7 {
+			kind = Month
+		} else {
+			kind = Week
+		}
+	}
+
+	today := startOfDay(asOf)
+
+	switch kind {
+	case Week:
+		// today.Weekday() returns 0=Sunday, 1=Monday,...6=Saturday
+		offset := int(today.Weekday())
+		if offset == 0 {
+			offset = 7 // Sunday → 7 days
+		}
+
+		// Last week's Monday = today - offset - 6
+		lastMonday := today.AddDate(0, 0, -offset-6)
+		return newPeriod(Week, lastMonday, lastMonday.AddDate(0, 0, 6)), nil
+
+	case Month:
+		firstOfThisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+		lastMonthStart := firstOfThisMonth.AddDate(0, -1, 0)
+		return newPeriod(Month, lastMonthStart, firstOfThisMonth.AddDate(0, 0, -1)), nil
+
+	case Quarter:
+		quarterStartMonth := time.Month((int(today.Month())-1)/3*3 + 1)
+		firstOfThisQuarter := time.Date(today.Year(), quarterStartMonth, 1, 0, 0, 0, 0, loc)
+		lastQuarterStart := firstOfThisQuarter.AddDate(0, -3, 0)
+		return newPeriod(Quarter, lastQuarterStart, firstOfThisQuarter.AddDate(0, 0, -1)), nil
+
+	case Custom:
+		if from == "" || to == "" {
+			return Period{}, errors.New("--period=custom requires both --from and --to")
+		}
+
+		fromDate, err := time.ParseInLocation(DateLayout, from, loc)
+		if err != nil {
+			return Period{}, fmt.Errorf("invalid --from date %q: %w", from, err)
+		}
+
+		toDate, err := time.ParseInLocation(DateLayout, to, loc)
+		if err != nil {
+			return Period{}, fmt.Errorf("invalid --to date %q: %w", to, err)
+		}
+
+		if toDate.Before(fromDate) {
+			return Period{}, fmt.Errorf("--to (%s) is before --from (%s)", to, from)
+		}
+
+		return newPeriod(Custom, fromDate, toDate), nil
+	}
+
+	return Period{}, fmt.Errorf("unsupported period %q", kind)
+}
+
+// Contains reports whether t falls inside [From, To)
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.From) && t.Before(p.To)
+}
+
+// LastDay returns midnight of the last day included in the period
+func (p Period) LastDay() time.Time {
+	return p.To.AddDate(0, 0, -1)
+}
+
+// Days returns the number of calendar days covered by the period
+func (p Period) Days() int {
+	days := 0
+	for day := p.From; day.Before(p.To); day = day.AddDate(0, 0, 1) {
+		days++
+	}
+	return days
+}
+
+// Previous returns the period of the same kind just before this one: the
+// previous week, calendar month or quarter, or as many days for custom periods
+func (p Period) Previous() Period {
+	switch p.Kind {
+	case Month:
+		return Period{Kind: p.Kind, From: p.From.AddDate(0, -1, 0), To: p.From}
+	case Quarter:
+		return Period{Kind: p.Kind, From: p.From.AddDate(0, -3, 0), To: p.From}
+	default:
+		return Period{Kind: p.Kind, From: p.From.AddDate(0, 0, -p.Days()), To: p.From}
+	}
+}
+
+// YearAgo returns the same period one year earlier. Weeks go back 52 weeks
+// so they still start on a Monday.
+func (p Period) YearAgo() Period {
+	if p.Kind == Week {
+		return Period{Kind: p.Kind, From: p.From.AddDate(0, 0, -364), To: p.To.AddDate(0, 0, -364)}
+	}
+
+	// Month and quarter boundaries are the 1st, so a year back never overflows
+	// into the next month; custom ranges starting on 29 February move to 1 March
+	return Period{Kind: p.Kind, From: p.From.AddDate(-1, 0, 0), To: p.To.AddDate(-1, 0, 0)}
+}
+
+// String formats the period for logs, e.g. "MONTH 2026-01-01 - 2026-01-31"
+func (p Period) String() string {
+	return fmt.Sprintf("%s %s - %s",
+		strings.ToUpper(string(p.Kind)),
+		p.From.Format(DateLayout),
+		p.LastDay().Format(DateLayout),
+	)
+}
+
+// newPeriod builds [firstDay, lastDay + 1 day) using calendar arithmetic,
+// so the boundaries stay on midnight even in zones with DST
+func newPeriod(kind Kind, firstDay, lastDay time.Time) Period {
+	return Period{
+		Kind: kind,
+		From: startOfDay(firstDay),
+		To:   startOfDay(lastDay).AddDate(0, 0, 1),
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,194 @@
+package period_test
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+var brisbane = time.FixedZone("AEST", 10*60*60)
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation(period.DateLayout, s, brisbane)
+	if err != nil {
+		t.Fatalf("bad test date %q: %v", s, err)
+	}
+	return d
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     period.Kind
+		asOf     string
+		from, to string
+
+		wantKind period.Kind
+		wantFrom string
+		wantTo   string // half-open: the day after the last one
+		wantErr  bool
+	}{
+		{name: "auto on the 1st is last month", kind: period.Auto, asOf: "2026-03-01", wantKind: period.Month, wantFrom: "2026-02-01", wantTo: "2026-03-01"},
+		{name: "auto on the 7th is last month", kind: period.Auto, asOf: "2026-03-07", wantKind: period.Month, wantFrom: "2026-02-01", wantTo: "2026-03-01"},
+		{name: "auto on the 8th is last week", kind: period.Auto, asOf: "2026-03-08", wantKind: period.Week, wantFrom: "2026-02-23", wantTo: "2026-03-02"},
+		{name: "auto on Monday the 7th is last month", kind: period.Auto, asOf: "2025-07-07", wantKind: period.Month, wantFrom: "2025-06-01", wantTo: "2025-07-01"},
+		{name: "auto on Tuesday the 8th is last week", kind: period.Auto, asOf: "2025-07-08", wantKind: period.Week, wantFrom: "2025-06-30", wantTo: "2025-07-07"},
+		{name: "week on a Sunday is the week before", kind: period.Week, asOf: "2026-03-08", wantKind: period.Week, wantFrom: "2026-02-23", wantTo: "2026-03-02"},
+		{name: "week on a Monday", kind: period.Week, asOf: "2026-03-09", wantKind: period.Week, wantFrom: "2026-03-02", wantTo: "2026-03-09"},
+		{name: "month across a year", kind: period.Month, asOf: "2026-01-15", wantKind: period.Month, wantFrom: "2025-12-01", wantTo: "2026-01-01"},
+		{name: "quarter on its first day", kind: period.Quarter, asOf: "2026-04-01", wantKind: period.Quarter, wantFrom: "2026-01-01", wantTo: "2026-04-01"},
+		{name: "quarter on the last day of one", kind: period.Quarter, asOf: "2026-03-31", wantKind: period.Quarter, wantFrom: "2025-10-01", wantTo: "2026-01-01"},
+		{name: "custom ending on the 31st", kind: period.Custom, asOf: "2026-03-03", from: "2026-01-01", to: "2026-01-31", wantKind: period.Custom, wantFrom: "2026-01-01", wantTo: "2026-02-01"},
+		{name: "custom single day", kind: period.Custom, asOf: "2026-03-03", from: "2026-02-28", to: "2026-02-28", wantKind: period.Custom, wantFrom: "2026-02-28", wantTo: "2026-03-01"},
+		{name: "custom to before from", kind: period.Custom, asOf: "2026-03-03", from: "2026-02-02", to: "2026-02-01", wantErr: true},
+		{name: "custom without to", kind: period.Custom, asOf: "2026-03-03", from: "2026-02-02", wantErr: true},
+		{name: "from without custom", kind: period.Month, asOf: "2026-03-03", from: "2026-02-02", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Late in the day, to check the time of day is dropped
+			asOf := day(t, tt.asOf).Add(23 * time.Hour)
+
+			p, err := period.Resolve(tt.kind, asOf, tt.from, tt.to, brisbane)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Resolve: want an error, got %s", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+
+			if p.Kind != tt.wantKind {
+				t.Errorf("Kind = %s, want %s", p.Kind, tt.wantKind)
+			}
+			if !p.From.Equal(day(t, tt.wantFrom)) {
+				t.Errorf("From = %s, want %s", p.From, tt.wantFrom)
+			}
+			if !p.To.Equal(day(t, tt.wantTo)) {
+				t.Errorf("To = %s, want %s", p.To, tt.wantTo)
+			}
+			if !p.LastDay().Equal(day(t, tt.wantTo).AddDate(0, 0, -1)) {
+				t.Errorf("LastDay = %s, want the day before %s", p.LastDay(), tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	p, err := period.Resolve(period.Month, day(t, "2026-03-03"), "", "", brisbane)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{day(t, "2026-01-31").Add(24*time.Hour - time.Second), false},
+		{day(t, "2026-02-01"), true},
+		{day(t, "2026-02-28"), true},
+		{day(t, "2026-02-28").Add(24*time.Hour - time.Second), true},
+		{day(t, "2026-03-01"), false},
+	}
+
+	for _, tt := range tests {
+		if got := p.Contains(tt.at); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestPreviousAndYearAgo(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     period.Kind
+		asOf     string
+		from, to string
+
+		wantPrevious [2]string // From, To
+		wantYearAgo  [2]string
+	}{
+		{
+			name: "week", kind: period.Week, asOf: "2026-03-09",
+			wantPrevious: [2]string{"2026-02-23", "2026-03-02"},
+			wantYearAgo:  [2]string{"2025-03-03", "2025-03-10"}, // 52 weeks back, still a Monday
+		},
+		{
+			name: "month", kind: period.Month, asOf: "2026-04-02",
+			wantPrevious: [2]string{"2026-02-01", "2026-03-01"},
+			wantYearAgo:  [2]string{"2025-03-01", "2025-04-01"},
+		},
+		{
+			name: "quarter", kind: period.Quarter, asOf: "2026-04-01",
+			wantPrevious: [2]string{"2025-10-01", "2026-01-01"},
+			wantYearAgo:  [2]string{"2025-01-01", "2025-04-01"},
+		},
+		{
+			name: "custom", kind: period.Custom, asOf: "2026-03-03", from: "2026-01-21", to: "2026-01-31",
+			wantPrevious: [2]string{"2026-01-10", "2026-01-21"},
+			wantYearAgo:  [2]string{"2025-01-21", "2025-02-01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := period.Resolve(tt.kind, day(t, tt.asOf), tt.from, tt.to, brisbane)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+
+			for _, check := range []struct {
+				name string
+				got  period.Period
+				want [2]string
+			}{
+				{"Previous", p.Previous(), tt.wantPrevious},
+				{"YearAgo", p.YearAgo(), tt.wantYearAgo},
+			} {
+				if check.got.Kind != p.Kind {
+					t.Errorf("%s Kind = %s, want %s", check.name, check.got.Kind, p.Kind)
+				}
+				if !check.got.From.Equal(day(t, check.want[0])) || !check.got.To.Equal(day(t, check.want[1])) {
+					t.Errorf("%s = [%s, %s), want [%s, %s)", check.name,
+						check.got.From.Format(period.DateLayout), check.got.To.Format(period.DateLayout),
+						check.want[0], check.want[1])
+				}
+			}
+		})
+	}
+}
+
+// TestLastDayIsCounted checks the aggregator keeps jobs dated on the last day
+// of the half-open range and leaves out the day after
+func TestLastDayIsCounted(t *testing.T) {
+	p, err := period.Resolve(period.Custom, day(t, "2026-03-03"), "2026-01-01", "2026-01-31", brisbane)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	agg := report.NewAggregator(p, report.Options{Status: "completed", PricePolicy: config.PriceReject})
+	for _, date := range []string{"2026-01-31", "2026-02-01"} {
+		job := api.Job{ID: date, Status: "completed", Date: date, Type: "Delivery", JobPrice: "1.00"}
+		if err := agg.Add(job, report.ParseAmounts(zap.NewNop(), job, config.PriceReject)); err != nil {
+			t.Fatalf("Add(%s): %v", date, err)
+		}
+	}
+
+	rpt := agg.Report()
+	if rpt.Included != 1 {
+		t.Errorf("Included = %d, want 1 (the job on 2026-01-31)", rpt.Included)
+	}
+	if got := rpt.Excluded[report.ExcludedAfterRange]; got != 1 {
+		t.Errorf("Excluded[%s] = %d, want 1 (the job on 2026-02-01)", report.ExcludedAfterRange, got)
+	}
+}
@@ -1,72 +1,190 @@
 package processor
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
 )
 
+// canonicalHourPattern matches times without minutes, e.g. "8AM"
+var canonicalHourPattern = regexp.MustCompile(`^(\d{1,2})(AM|PM)$`)
+
+// Rules that can produce a Result
+const (
+	MatchCanonical = "canonical"  // already in canonical form
+	MatchPrefixed  = "wcp_prefix" // "WCPNORTH-8AM" style, reformatted
+	MatchRouteTime = "route_time" // "NORTH 8AM" / "8AM SOUTH" style, reformatted
+	MatchNone      = "unmatched"  // route or time missing, passed through
+	MatchInferred  = "inferred"   // no run number, guessed by an inference rule
+	MatchEmpty     = "unassigned" // no run number and no inference rule matched
+)
+
+// Result describes how a raw run number was normalized
+type Result struct {
+	Raw        string  // run number as typed in Detrack
+	Value      string  // canonical run, or the cleaned raw value when unmatched
+	Route      string  // canonical route, empty when unmatched
+	Time       string  // canonical time, empty when unmatched
+	Rule       string  // one of the Match* constants
+	Confidence float64 // 1 for canonical input, lower the more rewriting was needed, 0 when unmatched
+	Unmatched  bool
+	Inference  string // name of the inference rule used, MatchInferred only
+}
+
+// compiledInference is an InferenceRule with its patterns compiled
+type compiledInference struct {
+	rule       InferenceRule
+	doNumber   *regexp.Regexp
+	timeWindow *regexp.Regexp
+	result     Result
+}
+
 // RunNumberNormalizer handles normalization of run numbers
 type RunNumberNormalizer struct {
-	aliases map[string]string
+	rules        *Rules
+	routeAliases map[string]string
+	timeAliases  map[string]string
+	routePattern *regexp.Regexp
+	timePattern  *regexp.Regexp
+	datePatterns []*regexp.Regexp
+	inference    []compiledInference
 }
 
-// NewRunNumberNormalizer creates a new normalizer with predefined aliases
-func NewRunNumberNormalizer() *RunNumberNormalizer {
-	return &RunNumberNormalizer{
-		aliases: map[string]string {
-			// Time variations
-			"8:00AM":  "8:00AM",
-			"8AM":     "8:00AM",
-			"10:30AM": "10:30AM",
-			"12PM":    "12:00PM",
-			"12:00PM": "12:00PM",
-			"1PM":     "1:00PM",
-			"1:00PM":  "1:00PM",
-
-			// Route variations
-			"NORTH": "NORTH",
-			"SOUTH": "SOUTH",
-			"GC":    "GC",
-		},
+// NewRunNumberNormalizer creates a normalizer from a validated ruleset
+func NewRunNumberNormalizer(rules *Rules) (*RunNumberNormalizer, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := &RunNumberNormalizer{
+		rules:        rules,
+		routeAliases: make(map[string]string),
+		timeAliases:  make(map[string]string),
+		timePattern:  regexp.MustCompile(rules.TimePattern),
+	}
+
+	// Canonical routes map to themselves, aliases to their route
+	for _, route := range rules.Routes {
+		route = strings.ToUpper(route)
+		n.routeAliases[route] = route
+	}
+	for alias, route := range rules.RouteAliases {
+		n.routeAliases[strings.ToUpper(alias)] = strings.ToUpper(route)
+	}
+
+	for alias, time := range rules.TimeAliases {
+		n.timeAliases[strings.ToUpper(strings.ReplaceAll(alias, " ", ""))] = time
+	}
+
+	// Longest names first so e.g. GCWEST wins over GC
+	names := make([]string, 0, len(n.routeAliases))
+	for name := range n.routeAliases {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	n.routePattern = regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+
+	for _, pattern := range rules.DatePatterns {
+		n.datePatterns = append(n.datePatterns, regexp.MustCompile(pattern))
 	}
+
+	for _, rule := range rules.Inference {
+		inferred := n.Resolve(rule.Run)
+		if inferred.Unmatched {
+			return nil, fmt.Errorf("inference rule %s: run %q does not match the rules", rule.Name, rule.Run)
+		}
+
+		compiled := compiledInference{rule: rule, result: inferred}
+		if rule.DoNumberPattern != "" {
+			compiled.doNumber = regexp.MustCompile(rule.DoNumberPattern)
+		}
+		if rule.TimeWindowPattern != "" {
+			compiled.timeWindow = regexp.MustCompile(rule.TimeWindowPattern)
+		}
+		n.inference = append(n.inference, compiled)
+	}
+
+	return n, nil
+}
+
+// ResolveJob normalizes the job's run number. Jobs without one are matched
+// against the inference rules and otherwise land in the unassigned bucket.
+func (n *RunNumberNormalizer) ResolveJob(job api.Job) Result {
+	if strings.TrimSpace(job.RunNumber) != "" {
+		return n.Resolve(job.RunNumber)
+	}
+
+	for _, inference := range n.inference {
+		if inference.doNumber != nil && !inference.doNumber.MatchString(job.DoNumber) {
+			continue
+		}
+		if inference.timeWindow != nil && !inference.timeWindow.MatchString(job.TimeWindow) {
+			continue
+		}
+
+		result := inference.result
+		result.Raw = job.RunNumber
+		result.Rule = MatchInferred
+		result.Confidence = 0.5
+		result.Inference = inference.rule.Name
+		return result
+	}
+
+	return Result{Raw: job.RunNumber, Value: n.rules.Unassigned, Rule: MatchEmpty}
 }
 
-// Normalize removes dates and standardizes the format 
-func (n *RunNumberNormalizer) Normalize(runNumber string) string {
-	// Remove date pattern (DD/DD/DD)
-	pattern := regexp.MustCompile(`\d{2}/\d{2}/\d{2}\s*`)
-	cleaned := pattern.ReplaceAllString(runNumber, "")
+// Resolve normalizes a run number and reports which rule matched
+func (n *RunNumberNormalizer) Resolve(runNumber string) Result {
+	// Remove date patterns (DD/DD/DD)
+	cleaned := runNumber
+	for _, pattern := range n.datePatterns {
+		cleaned = pattern.ReplaceAllString(cleaned, "")
+	}
 
 	// Trim white space
 	cleaned = strings.TrimSpace(cleaned)
 
-	if strings.HasPrefix(cleaned, "WCP") {
+	var result Result
+	if n.rules.Prefix != "" && strings.HasPrefix(cleaned, n.rules.Prefix) {
 		// Handle WCP prefix formats
-		return n.normalizeWCPPrefix(cleaned)
+		result = n.normalizeWCPPrefix(cleaned)
 	} else {
 		// Handle "ROUTE TIME" prefix formats
-		return n.normalizeRouteTimePrefix(cleaned)
+		result = n.normalizeRouteTimePrefix(cleaned)
+	}
+	result.Raw = runNumber
+
+	if !result.Unmatched && result.Value == cleaned {
+		result.Rule = MatchCanonical
+		result.Confidence = 1
 	}
+
+	return result
 }
 
 // extractRoute extracts the route from a string
 func (n *RunNumberNormalizer) extractRoute(s string) string {
-	pattern := regexp.MustCompile(`\b(NORTH|SOUTH|GC)\b`)
-	match := pattern.FindString(strings.ToUpper(s))
-	return match
+	return n.routePattern.FindString(strings.ToUpper(s))
 }
 
 // extractTime extracts the time from a string
 func (n *RunNumberNormalizer) extractTime(s string) string {
-	pattern := regexp.MustCompile(`\d{1,2}(?::\d{2})?\s*(?:AM|PM)`)
-	match := pattern.FindString(strings.ToUpper(s))
+	match := n.timePattern.FindString(strings.ToUpper(s))
 	return strings.TrimSpace(match)
 }
 
 // normalizeRoute normalizes route names
 func (n *RunNumberNormalizer) normalizeRoute(route string) string {
 	route = strings.ToUpper(strings.TrimSpace(route))
-	if normalized, ok := n.aliases[route]; ok {
+	if normalized, ok := n.routeAliases[route]; ok {
 		return normalized
 	}
 	return route
@@ -77,13 +195,12 @@ func (n *RunNumberNormalizer) normalizeTime(time string) string {
 	time = strings.ReplaceAll(strings.ToUpper(time), " ", "")
 
 	// Check if already in normalized format
-	if normalized, ok := n.aliases[time]; ok {
+	if normalized, ok := n.timeAliases[time]; ok {
 		return normalized
 	}
 
 	// Handle formats like "8AM" -> "8:00AM"
-	pattern := regexp.MustCompile(`^(\d{1,2})(AM|PM)$`)
-	if match := pattern.FindStringSubmatch(time); match != nil {
+	if match := canonicalHourPattern.FindStringSubmatch(time); match != nil {
 		hour := match[1]
 		period := match[2]
 		return hour + ":00" + period
@@ -92,10 +209,19 @@ func (n *RunNumberNormalizer) normalizeTime(time string) string {
 	return time
 }
 
+// format renders the canonical run number from the template
+func (n *RunNumberNormalizer) format(route, time string) string {
+	return strings.NewReplacer(
+		placeholderPrefix, n.rules.Prefix,
+		placeholderRoute, route,
+		placeholderTime, time,
+	).Replace(n.rules.Template)
+}
+
 // normalizeWCPPrefix handles formats like "WCPNORTH - 8:00AM"
-func (n *RunNumberNormalizer) normalizeWCPPrefix(s string) string {
+func (n *RunNumberNormalizer) normalizeWCPPrefix(s string) Result {
 	// Remove "WCP" prefix to decouple WCPGC
-	s = strings.TrimPrefix(s, "WCP")
+	s = strings.TrimPrefix(s, n.rules.Prefix)
 
 	// Remove hyphens and extract spaces then re-split
 	s = strings.ReplaceAll(s, "-", " ")
@@ -105,25 +231,26 @@ func (n *RunNumberNormalizer) normalizeWCPPrefix(s string) string {
 	time := n.extractTime(s)
 
 	if route == "" || time == "" {
-		return "WCP" + s
+		return Result{Value: n.rules.Prefix + s, Rule: MatchNone, Unmatched: true}
 	} else {
 		route = n.normalizeRoute(route)
 		time = n.normalizeTime(time)
-		return "WCP" + route + " - " + time
+		return Result{Value: n.format(route, time), Route: route, Time: time, Rule: MatchPrefixed, Confidence: 0.9}
 	}
 }
 
 // normalizeRouteTimePrefix handles formats like "NORTH 8:00AM" or "8AM SOUTH"
-func (n *RunNumberNormalizer) normalizeRouteTimePrefix(s string) string {
+func (n *RunNumberNormalizer) normalizeRouteTimePrefix(s string) Result {
 	route := n.extractRoute(s)
 	time := n.extractTime(s)
 
 	if route == "" || time == "" {
-		return s
+		return Result{Value: s, Rule: MatchNone, Unmatched: true}
 	}
 
 	route = n.normalizeRoute(route)
 	time = n.normalizeTime(time)
 
-	return "WCP" + route + " - " + time
-}
\ No newline at end of file
+	// No prefix means the run was typed free-form, so trust it a little less
+	return Result{Value: n.format(route, time), Route: route, Time: time, Rule: MatchRouteTime, Confidence: 0.75}
+}
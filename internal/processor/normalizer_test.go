@@ -0,0 +1,145 @@
+package processor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/processor"
+)
+
+// testRules are the defaults plus aliases, a route that contains another and
+// inference rules
+func testRules() *processor.Rules {
+	rules := processor.DefaultRules()
+	rules.Routes = append(rules.Routes, "GCWEST")
+	rules.RouteAliases = map[string]string{"NTH": "NORTH", "Sth": "south"}
+	rules.Inference = []processor.InferenceRule{
+		{Name: "north orders", DoNumberPattern: `^N`, Run: "NTH 8AM"},
+		{Name: "early south", DoNumberPattern: `^S`, TimeWindowPattern: `^0[6-9]:`, Run: "WCPSOUTH - 10:30AM"},
+		{Name: "mornings", TimeWindowPattern: `^0[6-9]:`, Run: "GC 12PM"},
+	}
+	return rules
+}
+
+func newNormalizer(t *testing.T) *processor.RunNumberNormalizer {
+	t.Helper()
+	n, err := processor.NewRunNumberNormalizer(testRules())
+	if err != nil {
+		t.Fatalf("NewRunNumberNormalizer: %v", err)
+	}
+	return n
+}
+
+func TestResolve(t *testing.T) {
+	n := newNormalizer(t)
+
+	tests := []struct {
+		raw            string
+		wantValue      string
+		wantRule       string
+		wantConfidence float64
+	}{
+		{"WCPNORTH - 8:00AM", "WCPNORTH - 8:00AM", processor.MatchCanonical, 1},
+		{"  WCPSOUTH - 10:30AM ", "WCPSOUTH - 10:30AM", processor.MatchCanonical, 1},
+		{"WCPNORTH-8AM", "WCPNORTH - 8:00AM", processor.MatchPrefixed, 0.9},
+		{"WCPGC 12 PM", "WCPGC - 12:00PM", processor.MatchPrefixed, 0.9},
+		{"24/12/25 WCPGC - 12PM", "WCPGC - 12:00PM", processor.MatchPrefixed, 0.9},
+		{"north 8am", "WCPNORTH - 8:00AM", processor.MatchRouteTime, 0.75},
+		{"8AM SOUTH", "WCPSOUTH - 8:00AM", processor.MatchRouteTime, 0.75},
+		{"9AM SOUTH", "WCPSOUTH - 9:00AM", processor.MatchRouteTime, 0.75},
+
+		// Aliases, matched ignoring case
+		{"NTH 1PM", "WCPNORTH - 1:00PM", processor.MatchRouteTime, 0.75},
+		{"WCPNTH-1:00 PM", "WCPNORTH - 1:00PM", processor.MatchPrefixed, 0.9},
+		{"sth 10:30 am", "WCPSOUTH - 10:30AM", processor.MatchRouteTime, 0.75},
+
+		// The longest route wins
+		{"WCPGCWEST - 8AM", "WCPGCWEST - 8:00AM", processor.MatchPrefixed, 0.9},
+
+		{"WCPWEST 1PM", "WCPWEST 1PM", processor.MatchNone, 0},
+		{"N0RTH", "N0RTH", processor.MatchNone, 0},
+		{"NORTH", "NORTH", processor.MatchNone, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got := n.Resolve(tt.raw)
+			if got.Value != tt.wantValue || got.Rule != tt.wantRule || got.Confidence != tt.wantConfidence {
+				t.Errorf("Resolve(%q) = %q, %s, %v, want %q, %s, %v", tt.raw, got.Value, got.Rule, got.Confidence, tt.wantValue, tt.wantRule, tt.wantConfidence)
+			}
+			if got.Raw != tt.raw {
+				t.Errorf("Raw = %q, want %q", got.Raw, tt.raw)
+			}
+			if got.Unmatched != (tt.wantRule == processor.MatchNone) {
+				t.Errorf("Unmatched = %v for rule %s", got.Unmatched, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestResolveJob(t *testing.T) {
+	n := newNormalizer(t)
+
+	tests := []struct {
+		name string
+		job  api.Job
+
+		wantValue     string
+		wantRule      string
+		wantInference string
+	}{
+		{
+			name:      "run number set wins over inference",
+			job:       api.Job{RunNumber: "NTH 1PM", DoNumber: "N1", TimeWindow: "07:00-09:00"},
+			wantValue: "WCPNORTH - 1:00PM", wantRule: processor.MatchRouteTime,
+		},
+		{
+			name:      "first matching rule wins",
+			job:       api.Job{DoNumber: "N1", TimeWindow: "07:00-09:00"},
+			wantValue: "WCPNORTH - 8:00AM", wantRule: processor.MatchInferred, wantInference: "north orders",
+		},
+		{
+			name:      "blank run number is inferred",
+			job:       api.Job{RunNumber: "  ", DoNumber: "N1"},
+			wantValue: "WCPNORTH - 8:00AM", wantRule: processor.MatchInferred, wantInference: "north orders",
+		},
+		{
+			name:      "every pattern of a rule must match",
+			job:       api.Job{DoNumber: "S1", TimeWindow: "07:00-09:00"},
+			wantValue: "WCPSOUTH - 10:30AM", wantRule: processor.MatchInferred, wantInference: "early south",
+		},
+		{
+			name:      "later rule when an earlier one half matches",
+			job:       api.Job{DoNumber: "X1", TimeWindow: "08:00-10:00"},
+			wantValue: "WCPGC - 12:00PM", wantRule: processor.MatchInferred, wantInference: "mornings",
+		},
+		{
+			name:      "unassigned when no rule matches",
+			job:       api.Job{DoNumber: "S1", TimeWindow: "14:00-16:00"},
+			wantValue: "UNASSIGNED", wantRule: processor.MatchEmpty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := n.ResolveJob(tt.job)
+			if got.Value != tt.wantValue || got.Rule != tt.wantRule || got.Inference != tt.wantInference {
+				t.Errorf("ResolveJob = %q, %s, %q, want %q, %s, %q", got.Value, got.Rule, got.Inference, tt.wantValue, tt.wantRule, tt.wantInference)
+			}
+			if tt.wantRule == processor.MatchInferred && got.Confidence != 0.5 {
+				t.Errorf("inferred Confidence = %v, want 0.5", got.Confidence)
+			}
+		})
+	}
+}
+
+func TestNewRunNumberNormalizerRejectsBadInference(t *testing.T) {
+	rules := processor.DefaultRules()
+	rules.Inference = []processor.InferenceRule{{Name: "west", DoNumberPattern: "^W", Run: "WEST 8AM"}}
+
+	_, err := processor.NewRunNumberNormalizer(rules)
+	if err == nil || !strings.Contains(err.Error(), "does not match the rules") {
+		t.Errorf("NewRunNumberNormalizer = %v, want an error for the unknown run", err)
+	}
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Placeholders available in Rules.Template
+const (
+	placeholderPrefix = "{prefix}"
+	placeholderRoute  = "{route}"
+	placeholderTime   = "{time}"
+)
+
+// Rules describes how raw run numbers are turned into canonical ones
+type Rules struct {
+	// Prefix marks the "WCPNORTH - 8:00AM" style and starts every canonical run
+	Prefix string `json:"prefix"`
+
+	// Routes are the canonical route names, e.g. NORTH
+	Routes []string `json:"routes"`
+
+	// RouteAliases maps alternative spellings to a canonical route, e.g. NTH → NORTH
+	RouteAliases map[string]string `json:"route_aliases"`
+
+	// TimeAliases maps time spellings (spaces removed, upper case) to a canonical time
+	TimeAliases map[string]string `json:"time_aliases"`
+
+	// TimePattern finds the time inside a run number
+	TimePattern string `json:"time_pattern"`
+
+	// DatePatterns are stripped from the run number before anything else
+	DatePatterns []string `json:"date_patterns"`
+
+	// Template builds the canonical run from {prefix}, {route} and {time}
+	Template string `json:"template"`
+
+	// Unassigned is the run reported for jobs without a run number
+	Unassigned string `json:"unassigned"`
+
+	// Inference guesses the run of jobs without a run number, first match wins
+	Inference []InferenceRule `json:"inference"`
+}
+
+// InferenceRule assigns a run to jobs without one. Every pattern that is set
+// must match; at least one is required.
+type InferenceRule struct {
+	Name              string `json:"name"`
+	DoNumberPattern   string `json:"do_number_pattern"`
+	TimeWindowPattern string `json:"time_window_pattern"`
+	Run               string `json:"run"` // normalized like any other run number
+}
+
+// DefaultRules returns the ruleset used when no rules file is configured
+func DefaultRules() *Rules {
+	return &Rules{
+		Prefix:       "WCP",
+		Routes:       []string{"NORTH", "SOUTH", "GC"},
+		RouteAliases: map[string]string{},
+		TimeAliases: map[string]string{
+			"8:00AM":  "8:00AM",
+			"8AM":     "8:00AM",
+			"10:30AM": "10:30AM",
+			"12PM":    "12:00PM",
+			"12:00PM": "12:00PM",
+			"1PM":     "1:00PM",
+			"1:00PM":  "1:00PM",
+		},
+		TimePattern: `\d{1,2}(?::\d{2})?\s*(?:AM|PM)`,
+		// Dates like 24/12/25 in front of the run
+		DatePatterns: []string{`\d{2}/\d{2}/\d{2}\s*`},
+		Template:     "{prefix}{route} - {time}",
+		Unassigned:   "UNASSIGNED",
+		Inference:    []InferenceRule{},
+	}
+}
+
+// LoadRules reads a JSON rules file. An empty path returns DefaultRules.
+// Fields missing from the file fall back to the defaults.
+func LoadRules(path string) (*Rules, error) {
+	rules := DefaultRules()
+	if path == "" {
+		return rules, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read normalizer rules: %w", err)
+	}
+
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse normalizer rules %s: %w", path, err)
+	}
+
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid normalizer rules %s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// Validate checks the rules can be compiled and produce canonical runs
+func (r *Rules) Validate() error {
+	if len(r.Routes) == 0 {
+		return errors.New("at least one route is required")
+	}
+
+	routes := make(map[string]bool, len(r.Routes))
+	for _, route := range r.Routes {
+		if strings.TrimSpace(route) == "" {
+			return errors.New("routes cannot be blank")
+		}
+		routes[strings.ToUpper(route)] = true
+	}
+
+	for alias, route := range r.RouteAliases {
+		if !routes[strings.ToUpper(route)] {
+			return fmt.Errorf("route alias %q points to unknown route %q", alias, route)
+		}
+	}
+
+	if _, err := regexp.Compile(r.TimePattern); err != nil {
+		return fmt.Errorf("invalid time_pattern: %w", err)
+	}
+
+	for _, pattern := range r.DatePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid date pattern %q: %w", pattern, err)
+		}
+	}
+
+	if strings.TrimSpace(r.Unassigned) == "" {
+		return errors.New("unassigned cannot be blank")
+	}
+
+	for i, rule := range r.Inference {
+		if rule.DoNumberPattern == "" && rule.TimeWindowPattern == "" {
+			return fmt.Errorf("inference rule %d (%s) needs do_number_pattern or time_window_pattern", i, rule.Name)
+		}
+		if strings.TrimSpace(rule.Run) == "" {
+			return fmt.Errorf("inference rule %d (%s) has no run", i, rule.Name)
+		}
+		for _, pattern := range []string{rule.DoNumberPattern, rule.TimeWindowPattern} {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("inference rule %d (%s) has invalid pattern %q: %w", i, rule.Name, pattern, err)
+			}
+		}
+	}
+
+	if !strings.Contains(r.Template, placeholderRoute) || !strings.Contains(r.Template, placeholderTime) {
+		return fmt.Errorf("template %q must contain %s and %s", r.Template, placeholderRoute, placeholderTime)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/processor"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(r *processor.Rules)
+		wantErr string // empty when valid
+	}{
+		{name: "defaults", change: func(r *processor.Rules) {}},
+		{name: "alias to a route", change: func(r *processor.Rules) { r.RouteAliases = map[string]string{"nth": "north"} }},
+		{name: "no routes", change: func(r *processor.Rules) { r.Routes = nil }, wantErr: "at least one route"},
+		{name: "blank route", change: func(r *processor.Rules) { r.Routes = append(r.Routes, " ") }, wantErr: "routes cannot be blank"},
+		{name: "alias to an unknown route", change: func(r *processor.Rules) { r.RouteAliases = map[string]string{"W": "WEST"} }, wantErr: `unknown route "WEST"`},
+		{name: "bad time pattern", change: func(r *processor.Rules) { r.TimePattern = `(\d` }, wantErr: "invalid time_pattern"},
+		{name: "bad date pattern", change: func(r *processor.Rules) { r.DatePatterns = []string{`[`} }, wantErr: "invalid date pattern"},
+		{name: "blank unassigned", change: func(r *processor.Rules) { r.Unassigned = "" }, wantErr: "unassigned cannot be blank"},
+		{name: "template without time", change: func(r *processor.Rules) { r.Template = "{prefix}{route}" }, wantErr: "must contain {route} and {time}"},
+		{
+			name:    "inference without a pattern",
+			change:  func(r *processor.Rules) { r.Inference = []processor.InferenceRule{{Name: "any", Run: "NORTH 8AM"}} },
+			wantErr: "needs do_number_pattern or time_window_pattern",
+		},
+		{
+			name:    "inference without a run",
+			change:  func(r *processor.Rules) { r.Inference = []processor.InferenceRule{{Name: "n", DoNumberPattern: "^N"}} },
+			wantErr: "has no run",
+		},
+		{
+			name: "inference with a bad pattern",
+			change: func(r *processor.Rules) {
+				r.Inference = []processor.InferenceRule{{Name: "n", TimeWindowPattern: "(", Run: "NORTH 8AM"}}
+			},
+			wantErr: "invalid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := processor.DefaultRules()
+			tt.change(rules)
+
+			err := rules.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // written to a rules file; empty loads path as is
+		path    string
+		wantErr string
+
+		wantRoutes []string
+		wantPrefix string
+	}{
+		{name: "no file uses the defaults", wantRoutes: []string{"NORTH", "SOUTH", "GC"}, wantPrefix: "WCP"},
+		{name: "shipped rules", path: "../../configs/normalizer_rules.json", wantRoutes: []string{"NORTH", "SOUTH", "GC"}, wantPrefix: "WCP"},
+		{name: "missing fields keep the defaults", file: `{"routes": ["NORTH", "WEST"]}`, wantRoutes: []string{"NORTH", "WEST"}, wantPrefix: "WCP"},
+		{name: "missing file", path: "testdata/none.json", wantErr: "failed to read normalizer rules"},
+		{name: "not JSON", file: `{"routes": [`, wantErr: "failed to parse normalizer rules"},
+		{name: "wrong type", file: `{"routes": "NORTH"}`, wantErr: "failed to parse normalizer rules"},
+		{name: "invalid rules", file: `{"route_aliases": {"W": "WEST"}}`, wantErr: "invalid normalizer rules"},
+		{name: "no routes", file: `{"routes": []}`, wantErr: "at least one route"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "rules.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rules, err := processor.LoadRules(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadRules = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRules: %v", err)
+			}
+			if strings.Join(rules.Routes, ",") != strings.Join(tt.wantRoutes, ",") || rules.Prefix != tt.wantPrefix {
+				t.Errorf("LoadRules = routes %q, prefix %q, want %q, %q", rules.Routes, rules.Prefix, tt.wantRoutes, tt.wantPrefix)
+			}
+		})
+	}
+}
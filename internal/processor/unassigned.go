@@ -0,0 +1,27 @@
+package processor
+
+import "github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+
+// UnassignedJob is a job that had no run number in Detrack
+type UnassignedJob struct {
+	ID         string `json:"id"`
+	DoNumber   string `json:"do_number"`
+	Date       string `json:"date"`
+	Type       string `json:"type"`
+	TimeWindow string `json:"time_window"`
+	ReportedAs string `json:"reported_as"` // inferred run or the unassigned bucket
+	Rule       string `json:"rule"`        // inference rule name, empty when not inferred
+}
+
+// NewUnassignedJob describes how a job without a run number was reported
+func NewUnassignedJob(job api.Job, result Result) UnassignedJob {
+	return UnassignedJob{
+		ID:         job.ID,
+		DoNumber:   job.DoNumber,
+		Date:       job.Date,
+		Type:       job.Type,
+		TimeWindow: job.TimeWindow,
+		ReportedAs: result.Value,
+		Rule:       result.Inference,
+	}
+}
@@ -0,0 +1,60 @@
+package processor
+
+import "sort"
+
+// UnmappedRun groups jobs whose run number did not match any rule
+type UnmappedRun struct {
+	Raw      string   `json:"raw_run_number"`
+	Value    string   `json:"reported_as"`
+	Jobs     int      `json:"job_count"`
+	Examples []string `json:"example_do_numbers"`
+}
+
+// UnmappedCollector tallies unmatched run numbers so dispatch can clean them up
+type UnmappedCollector struct {
+	maxExamples int
+	runs        map[string]*UnmappedRun
+}
+
+// NewUnmappedCollector keeps up to maxExamples DO numbers per raw run number
+func NewUnmappedCollector(maxExamples int) *UnmappedCollector {
+	return &UnmappedCollector{
+		maxExamples: maxExamples,
+		runs:        make(map[string]*UnmappedRun),
+	}
+}
+
+// Record counts a job if its run number was not matched
+func (c *UnmappedCollector) Record(result Result, doNumber string) {
+	if !result.Unmatched {
+		return
+	}
+
+	run, ok := c.runs[result.Raw]
+	if !ok {
+		run = &UnmappedRun{Raw: result.Raw, Value: result.Value}
+		c.runs[result.Raw] = run
+	}
+
+	run.Jobs++
+	if doNumber != "" && len(run.Examples) < c.maxExamples {
+		run.Examples = append(run.Examples, doNumber)
+	}
+}
+
+// Runs returns the unmapped run numbers, most frequent first
+func (c *UnmappedCollector) Runs() []UnmappedRun {
+	runs := make([]UnmappedRun, 0, len(c.runs))
+	for _, run := range c.runs {
+		runs = append(runs, *run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].Jobs != runs[j].Jobs {
+			return runs[i].Jobs > runs[j].Jobs
+		}
+		return runs[i].Raw < runs[j].Raw
+	})
+
+	return runs
+}
@@ -0,0 +1,39 @@
+package processor_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/processor"
+)
+
+func TestUnmappedCollector(t *testing.T) {
+	n := newNormalizer(t)
+	c := processor.NewUnmappedCollector(2)
+
+	for _, job := range []struct{ run, doNumber string }{
+		{"N0RTH", "D1"},
+		{"WCPNORTH - 8:00AM", "D2"}, // matched, not recorded
+		{"WCPWEST 1PM", "D3"},
+		{"N0RTH", ""}, // counted without an example
+		{"N0RTH", "D4"},
+		{"N0RTH", "D5"}, // over the example limit
+		{"WCPWEST 1PM", "D6"},
+		{"ZZZ", "D7"},
+	} {
+		c.Record(n.Resolve(job.run), job.doNumber)
+	}
+
+	want := []processor.UnmappedRun{
+		{Raw: "N0RTH", Value: "N0RTH", Jobs: 4, Examples: []string{"D1", "D4"}},
+		{Raw: "WCPWEST 1PM", Value: "WCPWEST 1PM", Jobs: 2, Examples: []string{"D3", "D6"}},
+		{Raw: "ZZZ", Value: "ZZZ", Jobs: 1, Examples: []string{"D7"}},
+	}
+	if got := c.Runs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Runs() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := processor.NewUnmappedCollector(3).Runs(); len(got) != 0 {
+		t.Errorf("empty collector Runs() = %+v, want none", got)
+	}
+}
@@ -0,0 +1,101 @@
+package recipients
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/notifier"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+// Group is a set of receivers with its own version of the report, e.g. a
+// route supervisor who only sees that route's runs
+type Group struct {
+	Name string `json:"name"` // added to the report file names, e.g. north
+	// Filter keeps the jobs to report by dimension, e.g. {"route": ["NORTH"]}; empty keeps every job
+	Filter report.Filter `json:"filter"`
+	// Sheets are the summaries and fixed sections to send, e.g. ["Report", "Jobs"]; empty sends all
+	Sheets []string `json:"sheets"`
+	// Schedule lists the periods the group gets a report for, e.g. ["month"]; empty is every run
+	Schedule []period.Kind `json:"schedule"`
+	// Channels are where the group's report is sent; email channels need receivers
+	Channels []notifier.ChannelConfig `json:"channels"`
+}
+
+// groupName keeps group names usable in file names
+var groupName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// LoadGroups reads a JSON list of groups; an empty path means none
+func LoadGroups(path string) ([]Group, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipient groups: %w", err)
+	}
+
+	var groups []Group
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("failed to parse recipient groups %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(groups))
+	for i := range groups {
+		g := &groups[i]
+		if err := g.check(); err != nil {
+			return nil, err
+		}
+		if names[g.Name] {
+			return nil, fmt.Errorf("recipient group %q listed twice", g.Name)
+		}
+		names[g.Name] = true
+	}
+
+	return groups, nil
+}
+
+// check validates the group, normalizing its schedule
+func (g *Group) check() error {
+	if !groupName.MatchString(g.Name) {
+		return fmt.Errorf("recipient group %q: name must be lowercase letters, digits, _ or -", g.Name)
+	}
+
+	for i, kind := range g.Schedule {
+		k, err := period.ParseKind(string(kind))
+		if err != nil || k == period.Auto {
+			return fmt.Errorf("recipient group %q: unknown schedule %q (want week, month, quarter or custom)", g.Name, kind)
+		}
+		g.Schedule[i] = k
+	}
+
+	if len(g.Channels) == 0 {
+		return fmt.Errorf("recipient group %q has no channels", g.Name)
+	}
+	// Without receivers an email channel falls back to EMAIL_RECEIVERS, who
+	// would get the filtered report
+	for _, c := range g.Channels {
+		if c.Type == notifier.ChannelSMTP && len(c.Receivers) == 0 {
+			return fmt.Errorf("recipient group %q: email channel %q has no receivers", g.Name, c.Name)
+		}
+	}
+
+	return nil
+}
+
+// Scheduled reports whether the group gets the report of a kind of period
+func (g Group) Scheduled(kind period.Kind) bool {
+	if len(g.Schedule) == 0 {
+		return true
+	}
+	for _, k := range g.Schedule {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
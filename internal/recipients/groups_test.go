@@ -0,0 +1,209 @@
+package recipients_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/notifier"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/output"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/recipients"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+// writeGroups writes a recipient groups file and returns its path
+func writeGroups(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "groups.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []recipients.Group
+		wantErr string
+	}{
+		{
+			name: "groups",
+			file: `[
+				{
+					"name": "north",
+					"filter": {"route": ["NORTH"]},
+					"sheets": ["Report", "Jobs"],
+					"schedule": ["Month", " week "],
+					"channels": [{"name": "supervisor", "type": "smtp", "receivers": ["north@example.com"]}]
+				},
+				{"name": "finance_team-2", "channels": [{"name": "chat", "type": "slack", "url_env": "FINANCE_SLACK_URL"}]}
+			]`,
+			want: []recipients.Group{
+				{
+					Name:     "north",
+					Filter:   report.Filter{"route": {"NORTH"}},
+					Sheets:   []string{"Report", "Jobs"},
+					Schedule: []period.Kind{period.Month, period.Week},
+					Channels: []notifier.ChannelConfig{{Name: "supervisor", Type: notifier.ChannelSMTP, Receivers: []string{"north@example.com"}}},
+				},
+				{
+					Name:     "finance_team-2",
+					Channels: []notifier.ChannelConfig{{Name: "chat", Type: notifier.ChannelSlack, URLEnv: "FINANCE_SLACK_URL"}},
+				},
+			},
+		},
+		{name: "no groups", file: `[]`, want: []recipients.Group{}},
+		{name: "not JSON", file: `[{"name": "north"`, wantErr: "failed to parse recipient groups"},
+		{name: "not a list", file: `{"name": "north"}`, wantErr: "failed to parse recipient groups"},
+		{
+			name:    "filter values must be a list",
+			file:    `[{"name": "north", "filter": {"route": "NORTH"}, "channels": [{"name": "c", "type": "slack"}]}]`,
+			wantErr: "failed to parse recipient groups",
+		},
+		{name: "no name", file: `[{"channels": [{"name": "c", "type": "slack"}]}]`, wantErr: `recipient group "": name must be`},
+		{name: "name unusable in file names", file: `[{"name": "North Team", "channels": [{"name": "c", "type": "slack"}]}]`, wantErr: "name must be lowercase"},
+		{
+			name:    "unknown schedule",
+			file:    `[{"name": "north", "schedule": ["daily"], "channels": [{"name": "c", "type": "slack"}]}]`,
+			wantErr: `unknown schedule "daily"`,
+		},
+		{
+			name:    "auto is not a schedule",
+			file:    `[{"name": "north", "schedule": ["auto"], "channels": [{"name": "c", "type": "slack"}]}]`,
+			wantErr: `unknown schedule "auto"`,
+		},
+		{name: "no channels", file: `[{"name": "north"}]`, wantErr: `recipient group "north" has no channels`},
+		{
+			name:    "email channel without receivers",
+			file:    `[{"name": "north", "channels": [{"name": "mail", "type": "smtp"}]}]`,
+			wantErr: `email channel "mail" has no receivers`,
+		},
+		{
+			name: "listed twice",
+			file: `[
+				{"name": "north", "channels": [{"name": "c", "type": "slack"}]},
+				{"name": "north", "channels": [{"name": "c", "type": "teams"}]}
+			]`,
+			wantErr: `recipient group "north" listed twice`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := recipients.LoadGroups(writeGroups(t, tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadGroups = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadGroups: %v", err)
+			}
+			if !reflect.DeepEqual(groups, tt.want) {
+				t.Errorf("LoadGroups =\n%+v\nwant\n%+v", groups, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadGroupsFiles(t *testing.T) {
+	if groups, err := recipients.LoadGroups(""); groups != nil || err != nil {
+		t.Errorf("LoadGroups without a file = %v, %v, want none", groups, err)
+	}
+
+	_, err := recipients.LoadGroups(filepath.Join(t.TempDir(), "none.json"))
+	if err == nil || !strings.Contains(err.Error(), "failed to read recipient groups") {
+		t.Errorf("LoadGroups of a missing file = %v, want a read error", err)
+	}
+}
+
+// TestGroupFilterAndSheets checks the filters and sheets of loaded groups
+// against the dimensions and summaries, as main does before fetching
+func TestGroupFilterAndSheets(t *testing.T) {
+	dimensions := report.NewDimensions(func(runNumber string) (string, string) {
+		route, slot, _ := strings.Cut(runNumber, "-")
+		return route, slot
+	})
+	summaries := []string{report.RunPivot.Name, report.DriverPivot.Name}
+
+	tests := []struct {
+		name   string
+		filter string // JSON, null when empty
+		sheets string
+
+		wantFilterErr string
+		wantSheetsErr string
+	}{
+		{name: "everything"},
+		{name: "route and sheets", filter: `{"route": ["north"], "job_type": ["Delivery"]}`, sheets: `["report", "Jobs", "Quarantine"]`},
+		{name: "unknown dimension", filter: `{"suburb": ["Carindale"]}`, wantFilterErr: `unknown dimension "suburb"`},
+		{name: "no filter values", filter: `{"route": []}`, wantFilterErr: `no values for "route"`},
+		{name: "unknown sheet", sheets: `["Report", "Invoices"]`, wantSheetsErr: `unknown sheet "Invoices"`},
+		{name: "no summary", sheets: `["Jobs"]`, wantSheetsErr: "at least one summary"},
+	}
+
+	orNull := func(value string) string {
+		if value == "" {
+			return "null"
+		}
+		return value
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := fmt.Sprintf(`[{"name": "north", "filter": %s, "sheets": %s, "channels": [{"name": "c", "type": "slack"}]}]`, orNull(tt.filter), orNull(tt.sheets))
+			groups, err := recipients.LoadGroups(writeGroups(t, file))
+			if err != nil {
+				t.Fatalf("LoadGroups: %v", err)
+			}
+			group := groups[0]
+
+			match, err := group.Filter.Match(dimensions)
+			if !errorContains(err, tt.wantFilterErr) {
+				t.Errorf("Filter.Match = %v, want an error containing %q", err, tt.wantFilterErr)
+			}
+			if err == nil && match != nil {
+				north := api.Job{RunNumber: "NORTH-AM", Type: "Delivery"}
+				south := api.Job{RunNumber: "SOUTH-AM", Type: "Delivery"}
+				if !match(north) || match(south) {
+					t.Errorf("filter keeps NORTH %v and SOUTH %v, want only NORTH", match(north), match(south))
+				}
+			}
+
+			err = output.CheckSheets(group.Sheets, summaries)
+			if !errorContains(err, tt.wantSheetsErr) {
+				t.Errorf("CheckSheets = %v, want an error containing %q", err, tt.wantSheetsErr)
+			}
+		})
+	}
+}
+
+func TestScheduled(t *testing.T) {
+	everyRun := recipients.Group{Name: "all"}
+	monthly := recipients.Group{Name: "finance", Schedule: []period.Kind{period.Month, period.Quarter}}
+
+	for _, kind := range []period.Kind{period.Week, period.Month, period.Quarter, period.Custom} {
+		if !everyRun.Scheduled(kind) {
+			t.Errorf("group without a schedule not scheduled for %s", kind)
+		}
+		if want := kind == period.Month || kind == period.Quarter; monthly.Scheduled(kind) != want {
+			t.Errorf("monthly Scheduled(%s) = %v, want %v", kind, monthly.Scheduled(kind), want)
+		}
+	}
+}
+
+// errorContains reports whether err contains want, or is nil when want is empty
+func errorContains(err error, want string) bool {
+	if want == "" {
+		return err == nil
+	}
+	return err != nil && strings.Contains(err.Error(), want)
+}
@@ -0,0 +1,371 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"go.uber.org/zap"
+)
+
+// Row holds the aggregated numbers for one group, e.g. one run number
+type Row struct {
+	Keys []string `json:"keys"` // one value per GroupBy dimension
+
+	NumOrdersDelivered int         `json:"num_orders_delivered"`
+	NumPartsDelivered  int         `json:"num_parts_delivered"`
+	NumOrdersPickedUp  int         `json:"num_orders_picked_up"`
+	NumPartsPickedUp   int         `json:"num_parts_picked_up"`
+	FreightRevenue     money.Cents `json:"freight_revenue"`
+	InvoicedAmount     money.Cents `json:"invoiced_amount"`  // sum of invoice_amount
+	CollectedAmount    money.Cents `json:"collected_amount"` // sum of payment_amount, collected on completion
+
+	// Only counted when Options.FailedStatus is set and those jobs are fetched
+	NumFailed int `json:"num_failed,omitempty"`
+	// Counted jobs with both a time window and a POD time, and how many of them were on time
+	NumTimed  int `json:"num_timed,omitempty"`
+	NumOnTime int `json:"num_on_time,omitempty"`
+}
+
+// OnTimeRate is the share of timed jobs completed by the end of their time
+// window; ok is false when no job could be timed
+func (r Row) OnTimeRate() (rate float64, ok bool) {
+	if r.NumTimed == 0 {
+		return 0, false
+	}
+	return float64(r.NumOnTime) / float64(r.NumTimed), true
+}
+
+// PriceIssue records a job with an amount that could not be parsed
+type PriceIssue struct {
+	JobID     string `json:"id"`
+	DoNumber  string `json:"do_number"`
+	Date      string `json:"date"`
+	RunNumber string `json:"run_number"`
+	Field     string `json:"field"` // job_price, invoice_amount or payment_amount
+	RawValue  string `json:"raw_value"`
+	Action    string `json:"action"` // the PRICE_POLICY applied
+}
+
+// ExclusionReason explains why a job was left out of the report
+type ExclusionReason string
+
+const (
+	ExcludedStatus      ExclusionReason = "status_mismatch"
+	ExcludedInvalidDate ExclusionReason = "invalid_date"
+	ExcludedBeforeRange ExclusionReason = "before_period"
+	ExcludedAfterRange  ExclusionReason = "on_or_after_period_end"
+	ExcludedBadPrice    ExclusionReason = "quarantined_price"
+)
+
+// ExclusionReasons lists every reason in the order they are reported
+var ExclusionReasons = []ExclusionReason{
+	ExcludedStatus,
+	ExcludedInvalidDate,
+	ExcludedBeforeRange,
+	ExcludedAfterRange,
+	ExcludedBadPrice,
+}
+
+// Report is the result of aggregating jobs over a period
+type Report struct {
+	Period   period.Period           `json:"-"`
+	GroupBy  []string                `json:"group_by"`
+	Rows     []Row                   `json:"rows"` // sorted by key
+	Totals   Row                     `json:"totals"`
+	Included int                     `json:"included"`
+	Excluded map[ExclusionReason]int `json:"excluded"`
+
+	// PriceIssues lists jobs with an unparseable amount, zeroed or quarantined
+	PriceIssues []PriceIssue `json:"price_issues"`
+}
+
+// Options controls which jobs are counted
+type Options struct {
+	Status      string // only jobs with this status are counted, e.g. completed
+	PricePolicy string // one of config.PriceReject, config.PriceZero, config.PriceQuarantine
+
+	// GroupBy defaults to ByRunNumber
+	GroupBy GroupBy
+	// FailedStatus jobs in the period are counted in NumFailed, e.g. failed; empty ignores them
+	FailedStatus string
+	// Match leaves out the jobs it returns false for, without counting them
+	// as excluded, e.g. the other routes of a route's report; nil keeps every job
+	Match func(job api.Job) bool
+}
+
+// Amounts are a job's parsed job_price, invoice_amount and payment_amount.
+// They are parsed once per job and shared by every aggregator it is added to.
+type Amounts struct {
+	Freight   money.Cents
+	Invoiced  money.Cents
+	Collected money.Cents
+
+	freightErr, invoicedErr, collectedErr error
+}
+
+// ParseAmounts parses a job's amounts, logging the ones the price policy
+// zeroes or quarantines. Under the reject policy Add returns the error instead.
+func ParseAmounts(logger *zap.Logger, job api.Job, policy string) Amounts {
+	var a Amounts
+	a.Freight, a.freightErr = job.Price()
+	a.Invoiced, a.invoicedErr = job.InvoiceAmount()
+	a.Collected, a.collectedErr = job.PaymentAmount()
+
+	if policy != config.PriceZero && policy != config.PriceQuarantine {
+		return a
+	}
+
+	if a.freightErr != nil {
+		message := "Failed to parse Job Price. Counting it as 0"
+		if policy == config.PriceQuarantine {
+			message = "Failed to parse Job Price. Quarantining the job"
+		}
+		logger.Warn(message, zap.String("jobID", job.ID), zap.Error(a.freightErr))
+	}
+	for _, amount := range []struct {
+		field string
+		err   error
+	}{{"invoice_amount", a.invoicedErr}, {"payment_amount", a.collectedErr}} {
+		if amount.err != nil {
+			logger.Warn("Failed to parse amount. Counting it as 0",
+				zap.String("jobID", job.ID),
+				zap.String("field", amount.field),
+				zap.Error(amount.err),
+			)
+		}
+	}
+
+	return a
+}
+
+// Aggregator builds a Report one job at a time
+type Aggregator struct {
+	period   period.Period
+	opts     Options
+	entries  map[string]*Row
+	included int
+	excluded map[ExclusionReason]int
+	issues   []PriceIssue
+}
+
+// NewAggregator creates an aggregator for the given period
+func NewAggregator(p period.Period, opts Options) *Aggregator {
+	if len(opts.GroupBy) == 0 {
+		opts.GroupBy = ByRunNumber
+	}
+
+	return &Aggregator{
+		period:   p,
+		opts:     opts,
+		entries:  make(map[string]*Row),
+		excluded: make(map[ExclusionReason]int),
+	}
+}
+
+// Aggregate is a shortcut to add every job and build the report
+func Aggregate(logger *zap.Logger, jobs []api.Job, p period.Period, opts Options) (*Report, error) {
+	agg := NewAggregator(p, opts)
+	for _, job := range jobs {
+		if err := agg.Add(job, ParseAmounts(logger, job, opts.PricePolicy)); err != nil {
+			return nil, err
+		}
+	}
+	return agg.Report(), nil
+}
+
+// Add counts a job with its parsed amounts if it matches the status and falls
+// inside [From, To). It only fails for an unparseable price under the reject policy.
+func (a *Aggregator) Add(job api.Job, amounts Amounts) error {
+	if a.opts.Match != nil && !a.opts.Match(job) {
+		return nil
+	}
+
+	if reason, excluded := a.exclusion(job); excluded {
+		a.excluded[reason]++
+
+		// Failed jobs are not counted as delivered, but do count against their group
+		if reason == ExcludedStatus && a.opts.FailedStatus != "" && job.Status == a.opts.FailedStatus {
+			if _, outside := a.dateExclusion(job); !outside {
+				a.entry(job).NumFailed++
+			}
+		}
+		return nil
+	}
+
+	freight := amounts.Freight
+	if amounts.freightErr != nil {
+		switch a.opts.PricePolicy {
+		case config.PriceZero:
+			freight = 0
+		case config.PriceQuarantine:
+			a.excluded[ExcludedBadPrice]++
+			a.issues = append(a.issues, newPriceIssue(job, "job_price", job.JobPrice, a.opts.PricePolicy))
+			return nil
+		default:
+			return fmt.Errorf("job %s (%s): %w", job.ID, job.DoNumber, amounts.freightErr)
+		}
+		a.issues = append(a.issues, newPriceIssue(job, "job_price", job.JobPrice, a.opts.PricePolicy))
+	}
+
+	invoiced, err := a.amount(job, "invoice_amount", string(job.RawInvoiceAmount), amounts.Invoiced, amounts.invoicedErr)
+	if err != nil {
+		return err
+	}
+	collected, err := a.amount(job, "payment_amount", string(job.RawPaymentAmount), amounts.Collected, amounts.collectedErr)
+	if err != nil {
+		return err
+	}
+
+	a.included++
+
+	entry := a.entry(job)
+
+	// Anything that is not a delivery is counted as a pick up
+	if job.Type == "Delivery" {
+		entry.NumOrdersDelivered++
+		entry.NumPartsDelivered += int(job.ItemCount)
+	} else {
+		entry.NumOrdersPickedUp++
+		entry.NumPartsPickedUp += int(job.ItemCount)
+	}
+	entry.FreightRevenue += freight
+	entry.InvoicedAmount += invoiced
+	entry.CollectedAmount += collected
+
+	if onTime, timed := OnTime(job, a.period.From.Location()); timed {
+		entry.NumTimed++
+		if onTime {
+			entry.NumOnTime++
+		}
+	}
+
+	return nil
+}
+
+// entry returns the row of the job's group, creating it on first use
+func (a *Aggregator) entry(job api.Job) *Row {
+	keys := a.opts.GroupBy.keys(job)
+	key := rowKey(keys)
+
+	entry, isExists := a.entries[key]
+	if !isExists {
+		entry = &Row{Keys: keys}
+		a.entries[key] = entry
+	}
+	return entry
+}
+
+// amount applies the price policy to an invoiced or collected amount. The
+// freight revenue is still counted, so quarantine only leaves out the amount.
+func (a *Aggregator) amount(job api.Job, field, raw string, value money.Cents, err error) (money.Cents, error) {
+	if err == nil {
+		return value, nil
+	}
+
+	if a.opts.PricePolicy != config.PriceZero && a.opts.PricePolicy != config.PriceQuarantine {
+		return 0, fmt.Errorf("job %s (%s) %s: %w", job.ID, job.DoNumber, field, err)
+	}
+
+	a.issues = append(a.issues, newPriceIssue(job, field, raw, a.opts.PricePolicy))
+	return 0, nil
+}
+
+func newPriceIssue(job api.Job, field, raw, action string) PriceIssue {
+	return PriceIssue{
+		JobID:     job.ID,
+		DoNumber:  job.DoNumber,
+		Date:      job.Date,
+		RunNumber: job.RunNumber,
+		Field:     field,
+		RawValue:  raw,
+		Action:    action,
+	}
+}
+
+// exclusion returns the reason a job is not counted, if any
+func (a *Aggregator) exclusion(job api.Job) (ExclusionReason, bool) {
+	// Filter by status
+	if job.Status != a.opts.Status {
+		return ExcludedStatus, true
+	}
+
+	return a.dateExclusion(job)
+}
+
+// dateExclusion returns the reason a job's date is outside the period, if any
+func (a *Aggregator) dateExclusion(job api.Job) (ExclusionReason, bool) {
+	// Filter by date. Job dates are calendar days, so comparing midnight in the
+	// period's location against the half-open range keeps the last day in.
+	jobDate, err := time.ParseInLocation(period.DateLayout, job.Date, a.period.From.Location())
+	if err != nil {
+		return ExcludedInvalidDate, true
+	}
+	if jobDate.Before(a.period.From) {
+		return ExcludedBeforeRange, true
+	}
+	if !jobDate.Before(a.period.To) {
+		return ExcludedAfterRange, true
+	}
+
+	return "", false
+}
+
+// Report returns the rows sorted by key together with the totals
+func (a *Aggregator) Report() *Report {
+	r := &Report{
+		Period:      a.period,
+		GroupBy:     a.opts.GroupBy.Names(),
+		Rows:        make([]Row, 0, len(a.entries)),
+		Totals:      Row{Keys: totalKeys(len(a.opts.GroupBy))},
+		Included:    a.included,
+		Excluded:    make(map[ExclusionReason]int, len(a.excluded)),
+		PriceIssues: append([]PriceIssue(nil), a.issues...),
+	}
+
+	for reason, count := range a.excluded {
+		r.Excluded[reason] = count
+	}
+
+	for _, entry := range a.entries {
+		r.Rows = append(r.Rows, *entry)
+
+		r.Totals.NumOrdersDelivered += entry.NumOrdersDelivered
+		r.Totals.NumPartsDelivered += entry.NumPartsDelivered
+		r.Totals.NumOrdersPickedUp += entry.NumOrdersPickedUp
+		r.Totals.NumPartsPickedUp += entry.NumPartsPickedUp
+		r.Totals.FreightRevenue += entry.FreightRevenue
+		r.Totals.InvoicedAmount += entry.InvoicedAmount
+		r.Totals.CollectedAmount += entry.CollectedAmount
+		r.Totals.NumFailed += entry.NumFailed
+		r.Totals.NumTimed += entry.NumTimed
+		r.Totals.NumOnTime += entry.NumOnTime
+	}
+
+	sort.Slice(r.Rows, func(i, j int) bool {
+		return slices.Compare(r.Rows[i].Keys, r.Rows[j].Keys) < 0
+	})
+
+	return r
+}
+
+// totalKeys labels the totals row: TOTAL under the first dimension
+func totalKeys(dimensions int) []string {
+	keys := make([]string, dimensions)
+	keys[0] = "TOTAL"
+	return keys
+}
+
+// ExcludedTotal returns the number of jobs left out for any reason
+func (r *Report) ExcludedTotal() int {
+	total := 0
+	for _, count := range r.Excluded {
+		total += count
+	}
+	return total
+}
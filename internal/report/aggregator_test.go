@@ -0,0 +1,284 @@
+package report_test
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+var brisbane = time.FixedZone("AEST", 10*60*60)
+
+// february is the month reported on 2026-03-03: [2026-02-01, 2026-03-01)
+func february(t *testing.T) period.Period {
+	t.Helper()
+	p, err := period.Resolve(period.Month, time.Date(2026, 3, 3, 9, 0, 0, 0, brisbane), "", "", brisbane)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	return p
+}
+
+func job(id, date, runNumber, price string) api.Job {
+	return api.Job{
+		ID:        id,
+		Status:    "completed",
+		Date:      date,
+		Type:      "Delivery",
+		ItemCount: 1,
+		JobPrice:  price,
+		RunNumber: runNumber,
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	collection := job("c1", "2026-02-10", "NORTH", "3.00")
+	collection.Type = "Collection"
+	collection.ItemCount = 4
+	untyped := job("u1", "2026-02-10", "NORTH", "1.00")
+	untyped.Type = ""
+	failed := job("f1", "2026-02-10", "NORTH", "9.99")
+	failed.Status = "failed"
+	badInvoice := job("i1", "2026-02-10", "NORTH", "2.50")
+	badInvoice.RawInvoiceAmount = "N/A"
+	badInvoice.RawPaymentAmount = "1.25"
+	driverA := job("d1", "2026-02-10", "NORTH", "1.00")
+	driverA.AssignTo = "Driver A"
+	driverB := job("d2", "2026-02-11", "NORTH", "2.00")
+	driverB.AssignTo = " "
+
+	routes := report.NewDimensions(func(runNumber string) (string, string) {
+		route, slot, _ := strings.Cut(runNumber, "-")
+		return route, slot
+	})
+
+	tests := []struct {
+		name string
+		jobs []api.Job
+		opts report.Options
+
+		wantErr      bool
+		wantRows     [][]string
+		wantIncluded int
+		wantExcluded map[report.ExclusionReason]int
+		wantTotals   report.Row
+		wantIssues   []string // field:action
+	}{
+		{
+			name: "first and last day of the range",
+			jobs: []api.Job{
+				job("a", "2026-01-31", "NORTH", "1.00"),
+				job("b", "2026-02-01", "NORTH", "2.00"),
+				job("c", "2026-02-28", "NORTH", "3.00"),
+				job("d", "2026-03-01", "NORTH", "4.00"),
+				job("e", "28/02/2026", "NORTH", "5.00"),
+				failed,
+			},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceReject},
+			wantRows:     [][]string{{"NORTH"}},
+			wantIncluded: 2,
+			wantExcluded: map[report.ExclusionReason]int{
+				report.ExcludedBeforeRange: 1,
+				report.ExcludedAfterRange:  1,
+				report.ExcludedInvalidDate: 1,
+				report.ExcludedStatus:      1,
+			},
+			wantTotals: report.Row{NumOrdersDelivered: 2, NumPartsDelivered: 2, FreightRevenue: 500},
+		},
+		{
+			name:    "reject fails on a bad job_price",
+			jobs:    []api.Job{job("a", "2026-02-10", "NORTH", "1.00"), job("b", "2026-02-10", "NORTH", "abc")},
+			opts:    report.Options{Status: "completed", PricePolicy: config.PriceReject},
+			wantErr: true,
+		},
+		{
+			name:    "reject fails on a bad invoice_amount",
+			jobs:    []api.Job{badInvoice},
+			opts:    report.Options{Status: "completed", PricePolicy: config.PriceReject},
+			wantErr: true,
+		},
+		{
+			name:         "zero counts a bad job_price as 0",
+			jobs:         []api.Job{job("a", "2026-02-10", "NORTH", "1.00"), job("b", "2026-02-10", "NORTH", "abc")},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceZero},
+			wantRows:     [][]string{{"NORTH"}},
+			wantIncluded: 2,
+			wantExcluded: map[report.ExclusionReason]int{},
+			wantTotals:   report.Row{NumOrdersDelivered: 2, NumPartsDelivered: 2, FreightRevenue: 100},
+			wantIssues:   []string{"job_price:zero"},
+		},
+		{
+			name:         "quarantine leaves out a bad job_price",
+			jobs:         []api.Job{job("a", "2026-02-10", "NORTH", "1.00"), job("b", "2026-02-10", "NORTH", "abc")},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceQuarantine},
+			wantRows:     [][]string{{"NORTH"}},
+			wantIncluded: 1,
+			wantExcluded: map[report.ExclusionReason]int{report.ExcludedBadPrice: 1},
+			wantTotals:   report.Row{NumOrdersDelivered: 1, NumPartsDelivered: 1, FreightRevenue: 100},
+			wantIssues:   []string{"job_price:quarantine"},
+		},
+		{
+			name:         "quarantine keeps the freight of a bad invoice_amount",
+			jobs:         []api.Job{badInvoice},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceQuarantine},
+			wantRows:     [][]string{{"NORTH"}},
+			wantIncluded: 1,
+			wantExcluded: map[report.ExclusionReason]int{},
+			wantTotals:   report.Row{NumOrdersDelivered: 1, NumPartsDelivered: 1, FreightRevenue: 250, CollectedAmount: 125},
+			wantIssues:   []string{"invoice_amount:quarantine"},
+		},
+		{
+			name:         "anything but Delivery is a pick up",
+			jobs:         []api.Job{job("a", "2026-02-10", "NORTH", "2.00"), collection, untyped},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceReject},
+			wantRows:     [][]string{{"NORTH"}},
+			wantIncluded: 3,
+			wantExcluded: map[report.ExclusionReason]int{},
+			wantTotals: report.Row{
+				NumOrdersDelivered: 1, NumPartsDelivered: 1,
+				NumOrdersPickedUp: 2, NumPartsPickedUp: 5,
+				FreightRevenue: 600,
+			},
+		},
+		{
+			name:         "failed jobs count against their group",
+			jobs:         []api.Job{job("a", "2026-02-10", "NORTH", "2.00"), failed},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceReject, FailedStatus: "failed"},
+			wantRows:     [][]string{{"NORTH"}},
+			wantIncluded: 1,
+			wantExcluded: map[report.ExclusionReason]int{report.ExcludedStatus: 1},
+			wantTotals:   report.Row{NumOrdersDelivered: 1, NumPartsDelivered: 1, FreightRevenue: 200, NumFailed: 1},
+		},
+		{
+			name:         "group by driver",
+			jobs:         []api.Job{driverA, driverB},
+			opts:         report.Options{Status: "completed", PricePolicy: config.PriceReject, GroupBy: report.ByDriver},
+			wantRows:     [][]string{{"Driver A"}, {report.NoDriver}},
+			wantIncluded: 2,
+			wantExcluded: map[report.ExclusionReason]int{},
+			wantTotals:   report.Row{NumOrdersDelivered: 2, NumPartsDelivered: 2, FreightRevenue: 300},
+		},
+		{
+			name: "group by route and time slot",
+			jobs: []api.Job{
+				job("a", "2026-02-10", "SOUTH-AM", "1.00"),
+				job("b", "2026-02-10", "NORTH-PM", "1.00"),
+				job("c", "2026-02-11", "NORTH-AM", "1.00"),
+				job("d", "2026-02-11", "NORTH", "1.00"),
+			},
+			opts: report.Options{
+				Status:      "completed",
+				PricePolicy: config.PriceReject,
+				GroupBy:     report.GroupBy{routes["route"], routes["time_slot"]},
+			},
+			wantRows:     [][]string{{"NORTH", report.Blank}, {"NORTH", "AM"}, {"NORTH", "PM"}, {"SOUTH", "AM"}},
+			wantIncluded: 4,
+			wantExcluded: map[report.ExclusionReason]int{},
+			wantTotals:   report.Row{NumOrdersDelivered: 4, NumPartsDelivered: 4, FreightRevenue: 400},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpt, err := report.Aggregate(zap.NewNop(), tt.jobs, february(t), tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Aggregate: want an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Aggregate: %v", err)
+			}
+
+			var rows [][]string
+			for _, row := range rpt.Rows {
+				rows = append(rows, row.Keys)
+			}
+			if !slices.EqualFunc(rows, tt.wantRows, slices.Equal) {
+				t.Errorf("row keys = %q, want %q", rows, tt.wantRows)
+			}
+
+			if rpt.Included != tt.wantIncluded {
+				t.Errorf("Included = %d, want %d", rpt.Included, tt.wantIncluded)
+			}
+			if len(rpt.Excluded) != len(tt.wantExcluded) {
+				t.Errorf("Excluded = %v, want %v", rpt.Excluded, tt.wantExcluded)
+			}
+			for reason, want := range tt.wantExcluded {
+				if rpt.Excluded[reason] != want {
+					t.Errorf("Excluded[%s] = %d, want %d", reason, rpt.Excluded[reason], want)
+				}
+			}
+
+			totals := rpt.Totals
+			if len(totals.Keys) != len(rpt.GroupBy) || totals.Keys[0] != "TOTAL" {
+				t.Errorf("totals keys = %q, want TOTAL under %q", totals.Keys, rpt.GroupBy)
+			}
+			totals.Keys = nil
+			if !reflect.DeepEqual(totals, tt.wantTotals) {
+				t.Errorf("totals = %+v, want %+v", totals, tt.wantTotals)
+			}
+
+			var issues []string
+			for _, issue := range rpt.PriceIssues {
+				issues = append(issues, issue.Field+":"+issue.Action)
+			}
+			if !slices.Equal(issues, tt.wantIssues) {
+				t.Errorf("price issues = %q, want %q", issues, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func TestParseAmountsLogsOnce(t *testing.T) {
+	bad := job("b", "2026-02-10", "NORTH", "abc")
+	bad.RawPaymentAmount = "N/A"
+
+	tests := []struct {
+		policy       string
+		wantWarnings []string
+	}{
+		{config.PriceReject, nil},
+		{config.PriceZero, []string{"Failed to parse Job Price. Counting it as 0", "Failed to parse amount. Counting it as 0"}},
+		{config.PriceQuarantine, []string{"Failed to parse Job Price. Quarantining the job", "Failed to parse amount. Counting it as 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			amounts := report.ParseAmounts(zap.New(core), bad, tt.policy)
+
+			// The same amounts go to every pivot without logging again
+			opts := report.Options{Status: "completed", PricePolicy: tt.policy}
+			for _, groupBy := range []report.GroupBy{report.ByRunNumber, report.ByDriver} {
+				opts.GroupBy = groupBy
+				agg := report.NewAggregator(february(t), opts)
+				err := agg.Add(bad, amounts)
+				if (err != nil) != (tt.policy == config.PriceReject) {
+					t.Errorf("Add = %v", err)
+				}
+				if err == nil && len(agg.Report().PriceIssues) == 0 {
+					t.Error("Add recorded no price issue")
+				}
+			}
+
+			var warnings []string
+			for _, entry := range logs.All() {
+				warnings = append(warnings, entry.Message)
+			}
+			if !slices.Equal(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
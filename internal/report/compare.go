@@ -0,0 +1,164 @@
+package report
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+)
+
+// Measure is a number of a Row compared across periods
+type Measure struct {
+	Name  string
+	Money bool // the value is in cents
+	Value func(row Row) int64
+}
+
+var (
+	OrdersMeasure  = Measure{Name: "orders", Value: func(r Row) int64 { return int64(r.NumOrdersDelivered + r.NumOrdersPickedUp) }}
+	PartsMeasure   = Measure{Name: "parts", Value: func(r Row) int64 { return int64(r.NumPartsDelivered + r.NumPartsPickedUp) }}
+	FreightMeasure = Measure{Name: "freight_revenue", Money: true, Value: func(r Row) int64 { return int64(r.FreightRevenue) }}
+
+	// ComparedMeasures are the numbers shown next to each earlier period
+	ComparedMeasures = []Measure{OrdersMeasure, PartsMeasure, FreightMeasure}
+)
+
+// Baseline is the report of an earlier period, looked up by row keys
+type Baseline struct {
+	Name   string // e.g. config.ComparePrevious, used in column names
+	Period period.Period
+	rows   map[string]Row
+	order  []Row // the rows without the totals, in report order
+}
+
+// NewBaseline indexes an earlier period's report; its totals are found under the TOTAL keys
+func NewBaseline(name string, r *Report) Baseline {
+	b := Baseline{Name: name, Period: r.Period, rows: make(map[string]Row, len(r.Rows)+1)}
+	for _, row := range r.Rows {
+		b.rows[rowKey(row.Keys)] = row
+	}
+	b.rows[rowKey(r.Totals.Keys)] = r.Totals
+	b.order = r.Rows
+	return b
+}
+
+// Delta is how a measure moved from the baseline to the current period
+type Delta struct {
+	Before int64
+	After  int64
+}
+
+// Change is the absolute difference, After - Before
+func (d Delta) Change() int64 {
+	return d.After - d.Before
+}
+
+// Percent is the change relative to Before; ok is false when Before is 0
+func (d Delta) Percent() (percent float64, ok bool) {
+	if d.Before == 0 {
+		return 0, false
+	}
+	return float64(d.Change()) / float64(d.Before) * 100, true
+}
+
+// Delta compares a row of the current report with the row of the same keys;
+// rows missing from the baseline count as 0 there
+func (b Baseline) Delta(row Row, measure Measure) Delta {
+	return Delta{Before: measure.Value(b.rows[rowKey(row.Keys)]), After: measure.Value(row)}
+}
+
+// Gone returns the rows of the baseline that have no jobs in r, with zero
+// values, in baseline order
+func (b Baseline) Gone(r *Report) []Row {
+	current := make(map[string]bool, len(r.Rows))
+	for _, row := range r.Rows {
+		current[rowKey(row.Keys)] = true
+	}
+
+	gone := []Row{}
+	for _, row := range b.order {
+		if !current[rowKey(row.Keys)] {
+			gone = append(gone, Row{Keys: row.Keys})
+		}
+	}
+	return gone
+}
+
+// ComparedRows returns the rows shown next to the baselines: the rows of r,
+// then the rows of any baseline that have no jobs in r with zero values, then
+// the totals
+func ComparedRows(r *Report, baselines []Baseline) []Row {
+	rows := append([]Row{}, r.Rows...)
+	added := map[string]bool{}
+	for _, baseline := range baselines {
+		for _, row := range baseline.Gone(r) {
+			if key := rowKey(row.Keys); !added[key] {
+				added[key] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+	return append(rows, r.Totals)
+}
+
+// Mover is a row whose measure changed by at least the threshold
+type Mover struct {
+	Keys    []string
+	Measure Measure
+	Delta   Delta
+	Gone    bool // the row had jobs in the baseline and has none now
+}
+
+// Movers lists the rows whose measure moved by at least minPercent against
+// the baseline, largest absolute change first, at most limit rows. Rows of
+// the baseline missing from r dropped by 100%; rows new in this period have
+// no percentage and are left out.
+func (b Baseline) Movers(r *Report, measure Measure, minPercent float64, limit int) []Mover {
+	movers := []Mover{}
+	moved := func(delta Delta) bool {
+		percent, ok := delta.Percent()
+		return ok && (percent >= minPercent || percent <= -minPercent)
+	}
+
+	for _, row := range r.Rows {
+		if delta := b.Delta(row, measure); moved(delta) {
+			movers = append(movers, Mover{Keys: row.Keys, Measure: measure, Delta: delta})
+		}
+	}
+
+	for _, row := range b.Gone(r) {
+		if delta := b.Delta(row, measure); moved(delta) {
+			movers = append(movers, Mover{Keys: row.Keys, Measure: measure, Delta: delta, Gone: true})
+		}
+	}
+
+	sort.SliceStable(movers, func(i, j int) bool {
+		return abs(movers[i].Delta.Change()) > abs(movers[j].Delta.Change())
+	})
+
+	if len(movers) > limit {
+		movers = movers[:limit]
+	}
+	return movers
+}
+
+// FormatValue renders a measure value, money as A$ amounts
+func (m Measure) FormatValue(value int64) string {
+	if m.Money {
+		return money.Cents(value).String()
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+func rowKey(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
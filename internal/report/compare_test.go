@@ -0,0 +1,149 @@
+package report_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+func TestComparedRowsKeepGoneRows(t *testing.T) {
+	feb := february(t)
+	opts := report.Options{Status: "completed", PricePolicy: config.PriceReject}
+
+	current, err := report.Aggregate(zap.NewNop(), []api.Job{
+		job("n1", "2026-02-10", "NORTH", "5.00"),
+		job("e1", "2026-02-10", "EAST", "1.00"),
+	}, feb, opts)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	previous, err := report.Aggregate(zap.NewNop(), []api.Job{
+		job("n0", "2026-01-10", "NORTH", "4.00"),
+		job("s0", "2026-01-10", "SOUTH", "8.00"),
+	}, feb.Previous(), opts)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	yearAgo, err := report.Aggregate(zap.NewNop(), []api.Job{
+		job("s9", "2025-02-10", "SOUTH", "2.00"),
+		job("w9", "2025-02-10", "WEST", "3.00"),
+	}, feb.YearAgo(), opts)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+
+	baselines := []report.Baseline{
+		report.NewBaseline(config.ComparePrevious, previous),
+		report.NewBaseline(config.CompareLastYear, yearAgo),
+	}
+
+	var keys []string
+	rows := report.ComparedRows(current, baselines)
+	for _, row := range rows {
+		keys = append(keys, strings.Join(row.Keys, "/"))
+	}
+	// The current rows, then SOUTH and WEST once each with zeros, then the totals
+	want := []string{"EAST", "NORTH", "SOUTH", "WEST", "TOTAL"}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("ComparedRows = %q, want %q", keys, want)
+	}
+
+	south := rows[2]
+	if south.FreightRevenue != 0 || south.NumOrdersDelivered != 0 {
+		t.Errorf("gone row SOUTH = %+v, want zero values", south)
+	}
+	delta := baselines[0].Delta(south, report.FreightMeasure)
+	if delta.Before != 800 || delta.After != 0 {
+		t.Errorf("SOUTH freight against previous = %+v, want 800 -> 0", delta)
+	}
+	if percent, ok := delta.Percent(); !ok || percent != -100 {
+		t.Errorf("SOUTH freight change = %v, %v, want -100%%", percent, ok)
+	}
+
+	// Without baselines only the current rows and the totals are shown
+	if got := report.ComparedRows(current, nil); len(got) != len(current.Rows)+1 {
+		t.Errorf("ComparedRows without baselines = %d rows, want %d", len(got), len(current.Rows)+1)
+	}
+
+	movers := baselines[0].Movers(current, report.FreightMeasure, 20, 10)
+	var moved []string
+	for _, mover := range movers {
+		moved = append(moved, strings.Join(mover.Keys, "/"))
+		if name := strings.Join(mover.Keys, "/"); mover.Gone != (name == "SOUTH") {
+			t.Errorf("mover %s Gone = %v", name, mover.Gone)
+		}
+	}
+	// SOUTH dropped A$8.00, NORTH rose A$1.00 (25%); EAST is new and has no percentage
+	if !slices.Equal(moved, []string{"SOUTH", "NORTH"}) {
+		t.Errorf("Movers = %q, want [SOUTH NORTH]", moved)
+	}
+}
+
+func TestMovers(t *testing.T) {
+	feb := february(t)
+	opts := report.Options{Status: "completed", PricePolicy: config.PriceReject}
+
+	aggregate := func(jobs ...api.Job) *report.Report {
+		t.Helper()
+		r, err := report.Aggregate(zap.NewNop(), jobs, feb, opts)
+		if err != nil {
+			t.Fatalf("Aggregate: %v", err)
+		}
+		return r
+	}
+
+	// Freight before and after: NORTH 10 -> 13 (+30%), SOUTH 10 -> 9 (-10%),
+	// GC 5 -> 0 (gone), WEST 20 -> 10 (-50%), EAST new
+	baseline := report.NewBaseline(config.ComparePrevious, aggregate(
+		job("n", "2026-02-10", "NORTH", "10.00"),
+		job("s", "2026-02-10", "SOUTH", "10.00"),
+		job("g", "2026-02-10", "GC", "5.00"),
+		job("w1", "2026-02-10", "WEST", "10.00"),
+		job("w2", "2026-02-10", "WEST", "10.00"),
+	))
+	current := aggregate(
+		job("n", "2026-02-10", "NORTH", "13.00"),
+		job("s", "2026-02-10", "SOUTH", "9.00"),
+		job("w", "2026-02-10", "WEST", "10.00"),
+		job("e", "2026-02-10", "EAST", "50.00"),
+	)
+
+	tests := []struct {
+		name       string
+		measure    report.Measure
+		minPercent float64
+		limit      int
+		want       []string
+	}{
+		{name: "largest change first", measure: report.FreightMeasure, minPercent: 20, limit: 10, want: []string{"WEST", "GC", "NORTH"}},
+		{name: "threshold is inclusive", measure: report.FreightMeasure, minPercent: 10, limit: 10, want: []string{"WEST", "GC", "NORTH", "SOUTH"}},
+		{name: "limit", measure: report.FreightMeasure, minPercent: 0, limit: 2, want: []string{"WEST", "GC"}},
+		{name: "nothing moved enough", measure: report.FreightMeasure, minPercent: 150, limit: 10, want: []string{}},
+		// Both lost one order; ties keep the current rows before the gone ones
+		{name: "orders", measure: report.OrdersMeasure, minPercent: 50, limit: 10, want: []string{"WEST", "GC"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, mover := range baseline.Movers(current, tt.measure, tt.minPercent, tt.limit) {
+				got = append(got, strings.Join(mover.Keys, "/"))
+				if mover.Measure.Name != tt.measure.Name {
+					t.Errorf("mover %v measure = %s, want %s", mover.Keys, mover.Measure.Name, tt.measure.Name)
+				}
+				if mover.Gone != (mover.Keys[0] == "GC") {
+					t.Errorf("mover %v Gone = %v", mover.Keys, mover.Gone)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Movers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
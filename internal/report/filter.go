@@ -0,0 +1,58 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+)
+
+// Filter keeps the jobs whose dimension values are all listed, by dimension
+// name, e.g. {"route": ["NORTH"]}. Values are compared ignoring case.
+type Filter map[string][]string
+
+// Match resolves the filter's dimensions, failing on unknown names. An empty
+// filter returns nil, which keeps every job.
+func (f Filter) Match(dimensions map[string]Dimension) (func(job api.Job) bool, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+
+	// Check the dimensions in a fixed order so errors do not change between runs
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type allowed struct {
+		dimension Dimension
+		values    map[string]bool
+	}
+	checks := make([]allowed, 0, len(names))
+	for _, name := range names {
+		dimension, ok := dimensions[name]
+		if !ok {
+			return nil, fmt.Errorf("filter: unknown dimension %q", name)
+		}
+		if len(f[name]) == 0 {
+			return nil, fmt.Errorf("filter: no values for %q", name)
+		}
+
+		values := make(map[string]bool, len(f[name]))
+		for _, value := range f[name] {
+			values[strings.ToUpper(strings.TrimSpace(value))] = true
+		}
+		checks = append(checks, allowed{dimension, values})
+	}
+
+	return func(job api.Job) bool {
+		for _, check := range checks {
+			if !check.values[strings.ToUpper(check.dimension.Value(job))] {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
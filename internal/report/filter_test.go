@@ -0,0 +1,68 @@
+package report_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+func TestFilterMatch(t *testing.T) {
+	dimensions := report.NewDimensions(splitRun)
+
+	northAM := api.Job{RunNumber: "NORTH-AM", Type: "Delivery", AssignTo: "Driver A"}
+	southPM := api.Job{RunNumber: "SOUTH-PM", Type: "Collection"}
+
+	tests := []struct {
+		name   string
+		filter report.Filter
+
+		wantNil bool
+		want    []bool // northAM, southPM
+		wantErr string
+	}{
+		{name: "empty keeps every job", filter: report.Filter{}, wantNil: true},
+		{name: "nil keeps every job", wantNil: true},
+		{name: "one value", filter: report.Filter{"route": {"NORTH"}}, want: []bool{true, false}},
+		{name: "ignores case and spaces", filter: report.Filter{"route": {" south "}}, want: []bool{false, true}},
+		{name: "any listed value", filter: report.Filter{"route": {"NORTH", "SOUTH"}}, want: []bool{true, true}},
+		{name: "every dimension", filter: report.Filter{"route": {"NORTH", "SOUTH"}, "time_slot": {"PM"}}, want: []bool{false, true}},
+		{name: "blank values", filter: report.Filter{"driver": {report.NoDriver}}, want: []bool{false, true}},
+		{name: "unknown dimension", filter: report.Filter{"suburb": {"X"}}, wantErr: `unknown dimension "suburb"`},
+		{name: "no values", filter: report.Filter{"route": {}}, wantErr: `no values for "route"`},
+		{
+			// Names are checked in order, so the first unknown one is always reported
+			name:    "first unknown dimension by name",
+			filter:  report.Filter{"zone": {"X"}, "area": {"Y"}},
+			wantErr: `unknown dimension "area"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.filter.Match(dimensions)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Match = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+
+			if tt.wantNil {
+				if match != nil {
+					t.Error("Match returned a func, want nil to keep every job")
+				}
+				return
+			}
+			for i, j := range []api.Job{northAM, southPM} {
+				if got := match(j); got != tt.want[i] {
+					t.Errorf("match(%s) = %v, want %v", j.RunNumber, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
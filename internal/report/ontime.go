@@ -0,0 +1,72 @@
+package report
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+)
+
+// Layouts accepted for the end of a time_window, e.g. "09:00 - 12:00" or "9:00 AM - 12:00 PM"
+var windowLayouts = []string{"15:04", "3:04 PM", "3:04PM", "3 PM", "3PM"}
+
+// Layouts accepted for pod_time; the ones without a zone are in the period's location
+var podLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// OnTime reports whether a job's proof of delivery was captured by the end
+// of its time window on the job date. timed is false when the job has no
+// readable date, time window or POD time.
+func OnTime(job api.Job, loc *time.Location) (onTime, timed bool) {
+	day, err := time.ParseInLocation(period.DateLayout, job.Date, loc)
+	if err != nil {
+		return false, false
+	}
+
+	end, ok := windowEnd(job.TimeWindow)
+	if !ok {
+		return false, false
+	}
+	deadline := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+
+	pod, ok := parsePodTime(job.PodTime, loc)
+	if !ok {
+		return false, false
+	}
+
+	return !pod.After(deadline), true
+}
+
+// windowEnd parses the time after the last "-" of a time window
+func windowEnd(window string) (time.Time, bool) {
+	_, end, found := cutLast(window, "-")
+	if !found {
+		return time.Time{}, false
+	}
+
+	end = strings.ToUpper(strings.TrimSpace(end))
+	for _, layout := range windowLayouts {
+		if t, err := time.Parse(layout, end); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parsePodTime(value string, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range podLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
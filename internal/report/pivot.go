@@ -0,0 +1,255 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+)
+
+// Dimension is a job attribute rows can be grouped by
+type Dimension struct {
+	Name  string
+	Value func(job api.Job) string
+}
+
+// GroupBy picks the row each job is counted in: one key per dimension
+type GroupBy []Dimension
+
+// Names returns the key column headers
+func (g GroupBy) Names() []string {
+	names := make([]string, len(g))
+	for i, dimension := range g {
+		names[i] = dimension.Name
+	}
+	return names
+}
+
+func (g GroupBy) keys(job api.Job) []string {
+	keys := make([]string, len(g))
+	for i, dimension := range g {
+		keys[i] = dimension.Value(job)
+	}
+	return keys
+}
+
+// Blank is the key of jobs without a value for a dimension
+const Blank = "(blank)"
+
+// NoDriver is the driver key of jobs not assigned to anyone
+const NoDriver = "UNASSIGNED"
+
+var (
+	// RunNumberDimension is the normalized run number
+	RunNumberDimension = Dimension{Name: "run_number", Value: func(job api.Job) string { return job.RunNumber }}
+
+	// DriverDimension is the vehicle/driver the job is assigned to
+	DriverDimension = Dimension{Name: "driver", Value: func(job api.Job) string {
+		if driver := strings.TrimSpace(job.AssignTo); driver != "" {
+			return driver
+		}
+		return NoDriver
+	}}
+
+	// ByRunNumber is the default grouping of the run report
+	ByRunNumber = GroupBy{RunNumberDimension}
+	// ByDriver is the grouping of the drivers report
+	ByDriver = GroupBy{DriverDimension}
+)
+
+// RunParts splits a normalized run number into its route and time slot
+type RunParts func(runNumber string) (route, timeSlot string)
+
+// NewDimensions returns every dimension pivots can group by. The route and
+// time_slot dimensions come from runParts; without it they are blank. The
+// dimensions share a cache and are meant for one goroutine.
+func NewDimensions(runParts RunParts) map[string]Dimension {
+	// Runs repeat across many jobs, so split each one only once
+	parts := make(map[string][2]string)
+	part := func(job api.Job, i int) string {
+		if runParts == nil {
+			return Blank
+		}
+		split, ok := parts[job.RunNumber]
+		if !ok {
+			route, timeSlot := runParts(job.RunNumber)
+			split = [2]string{route, timeSlot}
+			parts[job.RunNumber] = split
+		}
+		return orBlank(split[i])
+	}
+
+	dimensions := []Dimension{
+		RunNumberDimension,
+		DriverDimension,
+		{"route", func(job api.Job) string { return part(job, 0) }},
+		{"time_slot", func(job api.Job) string { return part(job, 1) }},
+		{"day", func(job api.Job) string { return orBlank(job.Date) }},
+		{"week", weekOf},
+		{"month", func(job api.Job) string {
+			if day, err := time.Parse(period.DateLayout, job.Date); err == nil {
+				return day.Format("2006-01")
+			}
+			return Blank
+		}},
+		{"customer", func(job api.Job) string { return orBlank(job.Customer) }},
+		{"job_type", func(job api.Job) string { return orBlank(job.Type) }},
+	}
+
+	byName := make(map[string]Dimension, len(dimensions))
+	for _, dimension := range dimensions {
+		byName[dimension.Name] = dimension
+	}
+	return byName
+}
+
+// weekOf keys a job by the Monday its week starts on, e.g. 2026-02-02
+func weekOf(job api.Job) string {
+	day, err := time.Parse(period.DateLayout, job.Date)
+	if err != nil {
+		return Blank
+	}
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset).Format(period.DateLayout)
+}
+
+func orBlank(value string) string {
+	if value = strings.TrimSpace(value); value != "" {
+		return value
+	}
+	return Blank
+}
+
+// Metric is a column computed from a Row
+type Metric struct {
+	Name  string
+	Value func(row Row) any
+}
+
+// Metrics lists every metric a pivot can show, by column name
+var Metrics = []Metric{
+	{"num_jobs", func(r Row) any { return r.NumOrdersDelivered + r.NumOrdersPickedUp }},
+	{"num_orders_delivered", func(r Row) any { return r.NumOrdersDelivered }},
+	{"num_parts_delivered", func(r Row) any { return r.NumPartsDelivered }},
+	{"num_orders_picked_up", func(r Row) any { return r.NumOrdersPickedUp }},
+	{"num_parts_picked_up", func(r Row) any { return r.NumPartsPickedUp }},
+	{"freight_revenue", func(r Row) any { return r.FreightRevenue }},
+	{"avg_freight_per_job", func(r Row) any {
+		if jobs := r.NumOrdersDelivered + r.NumOrdersPickedUp; jobs > 0 {
+			return money.Cents(int64(r.FreightRevenue) / int64(jobs))
+		}
+		return money.Cents(0)
+	}},
+	{"invoiced_amount", func(r Row) any { return r.InvoicedAmount }},
+	{"collected_amount", func(r Row) any { return r.CollectedAmount }},
+	{"num_failed", func(r Row) any { return r.NumFailed }},
+	{"num_timed", func(r Row) any { return r.NumTimed }},
+	{"on_time_rate", func(r Row) any {
+		if rate, ok := r.OnTimeRate(); ok {
+			return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+		}
+		return ""
+	}},
+}
+
+// Pivot is a report grouped by any dimensions showing the chosen metrics,
+// as read from the PIVOTS file
+type Pivot struct {
+	Name    string   `json:"name"`     // sheet name
+	GroupBy []string `json:"group_by"` // dimension names, e.g. ["route", "week"]
+	Metrics []string `json:"metrics"`  // metric names, e.g. ["num_jobs", "freight_revenue"]
+	// Compare adds the previous and year-ago periods next to each row
+	Compare bool `json:"compare"`
+}
+
+var (
+	// RunPivot is the Report sheet
+	RunPivot = Pivot{
+		Name:    "Report",
+		GroupBy: []string{"run_number"},
+		Metrics: []string{"num_orders_delivered", "num_parts_delivered", "num_orders_picked_up", "num_parts_picked_up", "freight_revenue", "invoiced_amount", "collected_amount"},
+		Compare: true,
+	}
+
+	// DriverPivot is the Drivers sheet
+	DriverPivot = Pivot{
+		Name:    "Drivers",
+		GroupBy: []string{"driver"},
+		Metrics: []string{"num_orders_delivered", "num_parts_delivered", "num_orders_picked_up", "num_parts_picked_up", "freight_revenue", "num_failed", "num_timed", "on_time_rate"},
+	}
+)
+
+// LoadPivots reads a JSON list of pivots; an empty path means none
+func LoadPivots(path string) ([]Pivot, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pivots: %w", err)
+	}
+
+	var pivots []Pivot
+	if err := json.Unmarshal(data, &pivots); err != nil {
+		return nil, fmt.Errorf("failed to parse pivots %s: %w", path, err)
+	}
+
+	return pivots, nil
+}
+
+// Resolve looks up the pivot's dimensions and metrics, failing on unknown names
+func (p Pivot) Resolve(dimensions map[string]Dimension) (GroupBy, []Metric, error) {
+	if strings.TrimSpace(p.Name) == "" {
+		return nil, nil, errors.New("pivot without a name")
+	}
+	if len(p.GroupBy) == 0 || len(p.Metrics) == 0 {
+		return nil, nil, fmt.Errorf("pivot %q needs at least one group_by and one metric", p.Name)
+	}
+
+	groupBy := make(GroupBy, 0, len(p.GroupBy))
+	for _, name := range p.GroupBy {
+		dimension, ok := dimensions[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("pivot %q: unknown dimension %q", p.Name, name)
+		}
+		groupBy = append(groupBy, dimension)
+	}
+
+	metrics := make([]Metric, 0, len(p.Metrics))
+	for _, name := range p.Metrics {
+		metric, ok := findMetric(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("pivot %q: unknown metric %q", p.Name, name)
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return groupBy, metrics, nil
+}
+
+// CountsFailed reports whether the pivot shows failed jobs, which have to be fetched too
+func (p Pivot) CountsFailed() bool {
+	for _, name := range p.Metrics {
+		if name == "num_failed" {
+			return true
+		}
+	}
+	return false
+}
+
+func findMetric(name string) (Metric, bool) {
+	for _, metric := range Metrics {
+		if metric.Name == name {
+			return metric, true
+		}
+	}
+	return Metric{}, false
+}
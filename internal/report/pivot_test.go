@@ -0,0 +1,313 @@
+package report_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/config"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/report"
+)
+
+// splitRun splits "NORTH-AM" into its route and time slot
+func splitRun(runNumber string) (string, string) {
+	route, slot, _ := strings.Cut(runNumber, "-")
+	return route, slot
+}
+
+func TestDimensions(t *testing.T) {
+	full := api.Job{
+		Date:      "2026-02-11", // a Wednesday
+		Type:      "Collection",
+		RunNumber: "NORTH-AM",
+		AssignTo:  " Driver A ",
+		Customer:  "ACME",
+	}
+	sunday := api.Job{Date: "2026-02-08", RunNumber: "NORTH"}
+	empty := api.Job{Date: "08/02/2026", AssignTo: " ", Customer: " "}
+
+	dimensions := report.NewDimensions(splitRun)
+	withoutRuns := report.NewDimensions(nil)
+
+	tests := []struct {
+		dimension string
+		job       api.Job
+		want      string
+	}{
+		{"run_number", full, "NORTH-AM"},
+		{"driver", full, "Driver A"},
+		{"driver", empty, report.NoDriver},
+		{"route", full, "NORTH"},
+		{"time_slot", full, "AM"},
+		{"time_slot", sunday, report.Blank},
+		{"day", full, "2026-02-11"},
+		{"week", full, "2026-02-09"},
+		{"week", sunday, "2026-02-02"}, // weeks start on Monday
+		{"week", empty, report.Blank},
+		{"month", full, "2026-02"},
+		{"month", empty, report.Blank},
+		{"customer", full, "ACME"},
+		{"customer", empty, report.Blank},
+		{"job_type", full, "Collection"},
+		{"job_type", empty, report.Blank},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dimension+"/"+tt.want, func(t *testing.T) {
+			dimension, ok := dimensions[tt.dimension]
+			if !ok {
+				t.Fatalf("no %s dimension", tt.dimension)
+			}
+			if got := dimension.Value(tt.job); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.dimension, got, tt.want)
+			}
+		})
+	}
+
+	for _, name := range []string{"route", "time_slot"} {
+		if got := withoutRuns[name].Value(full); got != report.Blank {
+			t.Errorf("%s without RunParts = %q, want %q", name, got, report.Blank)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	row := report.Row{
+		NumOrdersDelivered: 3, NumPartsDelivered: 5,
+		NumOrdersPickedUp: 1, NumPartsPickedUp: 2,
+		FreightRevenue: 1001, InvoicedAmount: 800, CollectedAmount: 300,
+		NumFailed: 2, NumTimed: 3, NumOnTime: 2,
+	}
+
+	tests := []struct {
+		metric string
+		row    report.Row
+		want   any
+	}{
+		{"num_jobs", row, 4},
+		{"num_orders_delivered", row, 3},
+		{"num_parts_delivered", row, 5},
+		{"num_orders_picked_up", row, 1},
+		{"num_parts_picked_up", row, 2},
+		{"freight_revenue", row, money.Cents(1001)},
+		{"avg_freight_per_job", row, money.Cents(250)},
+		{"avg_freight_per_job", report.Row{}, money.Cents(0)},
+		{"invoiced_amount", row, money.Cents(800)},
+		{"collected_amount", row, money.Cents(300)},
+		{"num_failed", row, 2},
+		{"num_timed", row, 3},
+		{"on_time_rate", row, "66.7%"},
+		{"on_time_rate", report.Row{NumFailed: 1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			i := slices.IndexFunc(report.Metrics, func(m report.Metric) bool { return m.Name == tt.metric })
+			if i < 0 {
+				t.Fatalf("no %s metric", tt.metric)
+			}
+			if got := report.Metrics[i].Value(tt.row); got != tt.want {
+				t.Errorf("%s = %v (%T), want %v (%T)", tt.metric, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestPivotResolve(t *testing.T) {
+	dimensions := report.NewDimensions(splitRun)
+
+	tests := []struct {
+		name  string
+		pivot report.Pivot
+
+		wantGroupBy []string
+		wantMetrics []string
+		wantErr     string
+	}{
+		{name: "run pivot", pivot: report.RunPivot, wantGroupBy: []string{"run_number"}, wantMetrics: report.RunPivot.Metrics},
+		{name: "driver pivot", pivot: report.DriverPivot, wantGroupBy: []string{"driver"}, wantMetrics: report.DriverPivot.Metrics},
+		{
+			name:        "custom pivot",
+			pivot:       report.Pivot{Name: "Weekly", GroupBy: []string{"route", "week"}, Metrics: []string{"num_jobs", "freight_revenue"}},
+			wantGroupBy: []string{"route", "week"},
+			wantMetrics: []string{"num_jobs", "freight_revenue"},
+		},
+		{name: "no name", pivot: report.Pivot{Name: " ", GroupBy: []string{"route"}, Metrics: []string{"num_jobs"}}, wantErr: "without a name"},
+		{name: "no group_by", pivot: report.Pivot{Name: "P", Metrics: []string{"num_jobs"}}, wantErr: "at least one group_by"},
+		{name: "no metrics", pivot: report.Pivot{Name: "P", GroupBy: []string{"route"}}, wantErr: "at least one group_by and one metric"},
+		{name: "unknown dimension", pivot: report.Pivot{Name: "P", GroupBy: []string{"suburb"}, Metrics: []string{"num_jobs"}}, wantErr: `unknown dimension "suburb"`},
+		{name: "unknown metric", pivot: report.Pivot{Name: "P", GroupBy: []string{"route"}, Metrics: []string{"profit"}}, wantErr: `unknown metric "profit"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groupBy, metrics, err := tt.pivot.Resolve(dimensions)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Resolve = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+
+			if !slices.Equal(groupBy.Names(), tt.wantGroupBy) {
+				t.Errorf("group by = %q, want %q", groupBy.Names(), tt.wantGroupBy)
+			}
+			var names []string
+			for _, metric := range metrics {
+				names = append(names, metric.Name)
+			}
+			if !slices.Equal(names, tt.wantMetrics) {
+				t.Errorf("metrics = %q, want %q", names, tt.wantMetrics)
+			}
+		})
+	}
+}
+
+func TestLoadPivots(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // written to a pivots file; empty loads no file
+		want    []report.Pivot
+		wantErr string
+	}{
+		{name: "no file"},
+		{
+			name: "pivots",
+			file: `[{"name": "Weekly", "group_by": ["route", "week"], "metrics": ["num_jobs"], "compare": true}]`,
+			want: []report.Pivot{{Name: "Weekly", GroupBy: []string{"route", "week"}, Metrics: []string{"num_jobs"}, Compare: true}},
+		},
+		{name: "not a list", file: `{"name": "Weekly"}`, wantErr: "failed to parse pivots"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "pivots.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			pivots, err := report.LoadPivots(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadPivots = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPivots: %v", err)
+			}
+			if !reflect.DeepEqual(pivots, tt.want) {
+				t.Errorf("LoadPivots = %+v, want %+v", pivots, tt.want)
+			}
+		})
+	}
+
+	if _, err := report.LoadPivots(filepath.Join(t.TempDir(), "none.json")); err == nil || !strings.Contains(err.Error(), "failed to read pivots") {
+		t.Errorf("LoadPivots of a missing file = %v, want a read error", err)
+	}
+}
+
+func TestCountsFailedPivot(t *testing.T) {
+	if report.RunPivot.CountsFailed() || !report.DriverPivot.CountsFailed() {
+		t.Fatalf("CountsFailed = %v for runs, %v for drivers, want false, true", report.RunPivot.CountsFailed(), report.DriverPivot.CountsFailed())
+	}
+
+	withStatus := func(j api.Job, status string) api.Job {
+		j.Status = status
+		return j
+	}
+
+	// A pivot counting failures is fed every status, as main does
+	jobs := []api.Job{
+		job("c1", "2026-02-10", "NORTH-AM", "2.00"),
+		withStatus(job("f1", "2026-02-10", "NORTH-AM", "9.99"), "failed"),
+		withStatus(job("f2", "2026-02-11", "SOUTH-PM", "abc"), "failed"),  // no price is parsed for failures
+		withStatus(job("f3", "2026-03-01", "NORTH-AM", "1.00"), "failed"), // after the period
+		withStatus(job("x1", "2026-02-10", "NORTH-AM", "1.00"), "cancelled"),
+		withStatus(job("x2", "2026-02-10", "EAST-AM", "1.00"), "assigned"),
+		withStatus(job("x3", "2026-02-10", "NORTH-AM", "1.00"), "Failed"),
+	}
+
+	dimensions := report.NewDimensions(splitRun)
+	tests := []struct {
+		name   string
+		opts   report.Options
+		filter report.Filter
+
+		wantRows   map[string]int // num_failed by route
+		wantTotals report.Row
+	}{
+		{
+			name:     "counts only the failed status",
+			opts:     report.Options{FailedStatus: "failed"},
+			wantRows: map[string]int{"NORTH": 1, "SOUTH": 1},
+			wantTotals: report.Row{
+				NumOrdersDelivered: 1, NumPartsDelivered: 1, FreightRevenue: 200, NumFailed: 2,
+			},
+		},
+		{
+			name:       "filtered variant",
+			opts:       report.Options{FailedStatus: "failed"},
+			filter:     report.Filter{"route": {"south"}},
+			wantRows:   map[string]int{"SOUTH": 1},
+			wantTotals: report.Row{NumFailed: 1},
+		},
+		{
+			name:       "without FailedStatus the other statuses are only excluded",
+			wantRows:   map[string]int{"NORTH": 0},
+			wantTotals: report.Row{NumOrdersDelivered: 1, NumPartsDelivered: 1, FreightRevenue: 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Status = "completed"
+			opts.PricePolicy = config.PriceReject
+			opts.GroupBy = report.GroupBy{dimensions["route"]}
+			match, err := tt.filter.Match(dimensions)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			opts.Match = match
+
+			rpt, err := report.Aggregate(zap.NewNop(), jobs, february(t), opts)
+			if err != nil {
+				t.Fatalf("Aggregate: %v", err)
+			}
+
+			rows := map[string]int{}
+			for _, row := range rpt.Rows {
+				rows[row.Keys[0]] = row.NumFailed
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("num_failed by route = %v, want %v", rows, tt.wantRows)
+			}
+
+			totals := rpt.Totals
+			totals.Keys = nil
+			if !reflect.DeepEqual(totals, tt.wantTotals) {
+				t.Errorf("totals = %+v, want %+v", totals, tt.wantTotals)
+			}
+			if rpt.Included+rpt.ExcludedTotal() == 0 {
+				t.Error("no job was seen")
+			}
+			if rpt.Excluded[report.ExcludedBadPrice] != 0 || len(rpt.PriceIssues) != 0 {
+				t.Errorf("price of a failed job was checked: %v, %+v", rpt.Excluded, rpt.PriceIssues)
+			}
+		})
+	}
+}
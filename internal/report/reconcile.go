@@ -0,0 +1,105 @@
+package report
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/money"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+)
+
+// Reconciliation compares the report total with one recomputed from the Jobs sheet
+type Reconciliation struct {
+	Jobs          int         // jobs in the sheet that pass the report filters
+	SheetRevenue  money.Cents // freight revenue summed straight from the sheet
+	ReportRevenue money.Cents // freight revenue in the report totals
+}
+
+// Matches reports whether both totals agree to the cent
+func (r Reconciliation) Matches() bool {
+	return r.SheetRevenue == r.ReportRevenue
+}
+
+// Difference returns report revenue minus sheet revenue
+func (r Reconciliation) Difference() money.Cents {
+	return r.ReportRevenue - r.SheetRevenue
+}
+
+// ReconcileColumns are the Jobs sheet columns the Reconciler reads back
+var ReconcileColumns = []string{"status", "date", "job_price"}
+
+// Reconciler re-reads the rows written to the Jobs sheet and sums job_price
+// with its own status and date filters, sharing no code with the Aggregator.
+// A mismatch catches aggregation mistakes such as jobs counted twice, lost
+// between groups or under the wrong policy. It reads the rows as the writers
+// render them, not the saved files, so it does not catch a writer that drops
+// rows after rendering them.
+type Reconciler struct {
+	status   string
+	from, to time.Time
+	columns  map[string]int
+	result   Reconciliation
+}
+
+// NewReconciler locates the status, date and job_price columns in headers
+func NewReconciler(p period.Period, opts Options, headers []string) *Reconciler {
+	columns := make(map[string]int, len(ReconcileColumns))
+	for _, name := range ReconcileColumns {
+		columns[name] = -1
+	}
+	for i, header := range headers {
+		if _, ok := columns[header]; ok {
+			columns[header] = i
+		}
+	}
+
+	return &Reconciler{
+		status:  opts.Status,
+		from:    p.From,
+		to:      p.To,
+		columns: columns,
+	}
+}
+
+// AddRow counts one Jobs sheet row, as text
+func (r *Reconciler) AddRow(row []string) {
+	if !r.inReport(r.cell(row, "status"), r.cell(row, "date")) {
+		return
+	}
+
+	r.result.Jobs++
+
+	// Unparseable prices add nothing: they are either zeroed or quarantined
+	price, err := money.Parse(r.cell(row, "job_price"))
+	if err == nil {
+		r.result.SheetRevenue += price
+	}
+}
+
+// inReport keeps rows with the report status dated on a day of [from, to)
+func (r *Reconciler) inReport(status, date string) bool {
+	if status != r.status {
+		return false
+	}
+
+	day, err := time.ParseInLocation(period.DateLayout, strings.TrimSpace(date), r.from.Location())
+	if err != nil {
+		return false
+	}
+	return !day.Before(r.from) && day.Before(r.to)
+}
+
+// Result compares the rows added so far with the report totals
+func (r *Reconciler) Result(rpt *Report) Reconciliation {
+	result := r.result
+	result.ReportRevenue = rpt.Totals.FreightRevenue
+	return result
+}
+
+func (r *Reconciler) cell(row []string, header string) string {
+	index := r.columns[header]
+	if index >= 0 && index < len(row) {
+		return row[index]
+	}
+	return ""
+}
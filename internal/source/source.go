@@ -0,0 +1,225 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/period"
+)
+
+// pageSize is how many jobs a file source hands over at a time
+const pageSize = 500
+
+// JobSource streams the jobs dated in [from, to) that match filters
+type JobSource interface {
+	Jobs(ctx context.Context, from, to time.Time, filters api.JobFilters) *api.JobStream
+	// Name describes the source in logs and emails
+	Name() string
+}
+
+// APISource reads the jobs from Detrack, through the client's cache if it has one
+type APISource struct {
+	Client *api.DetrackClient
+}
+
+// NewAPISource constructor
+func NewAPISource(client *api.DetrackClient) *APISource {
+	return &APISource{Client: client}
+}
+
+func (s *APISource) Jobs(ctx context.Context, from, to time.Time, filters api.JobFilters) *api.JobStream {
+	return s.Client.StreamJobsInRange(ctx, from, to, filters)
+}
+
+func (s *APISource) Name() string {
+	return "Detrack API " + s.Client.BaseURL
+}
+
+// FileSource reads a captured snapshot of jobs. A .jsonl file holds one job
+// per line, e.g. a day of the job cache; any other file is JSON: a list of
+// jobs or a Detrack response ({"data": [...]}). Reports written by the JSON
+// output are not snapshots: their run numbers are already normalized and they
+// leave out the jobs the report excluded.
+//
+// The file is filtered like the API would: only jobs dated in the range and
+// matching the filters are returned. Jobs with an unreadable date are kept so
+// the report lists them as excluded instead of silently dropping them.
+type FileSource struct {
+	Path   string
+	Logger *zap.Logger
+}
+
+// NewFileSource constructor
+func NewFileSource(logger *zap.Logger, path string) *FileSource {
+	return &FileSource{Path: path, Logger: logger}
+}
+
+func (s *FileSource) Name() string {
+	return "snapshot " + filepath.Base(s.Path)
+}
+
+func (s *FileSource) Jobs(ctx context.Context, from, to time.Time, filters api.JobFilters) *api.JobStream {
+	return api.NewJobStream(ctx, func(ctx context.Context, emit api.Emit) error {
+		file, err := os.Open(s.Path)
+		if err != nil {
+			return fmt.Errorf("failed to open jobs file: %w", err)
+		}
+		defer file.Close()
+
+		read, skipped := 0, 0
+		page := make([]api.Job, 0, pageSize)
+		keep := func(job api.Job) error {
+			read++
+			if !matches(job, from, to, filters) {
+				skipped++
+				return nil
+			}
+
+			page = append(page, job)
+			if len(page) < pageSize {
+				return nil
+			}
+			if err := emit(page); err != nil {
+				return err
+			}
+			page = make([]api.Job, 0, pageSize)
+			return nil
+		}
+
+		if strings.EqualFold(filepath.Ext(s.Path), ".jsonl") {
+			err = readLines(file, keep)
+		} else {
+			err = readDocument(file, keep)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", s.Path, err)
+		}
+
+		if len(page) > 0 {
+			if err := emit(page); err != nil {
+				return err
+			}
+		}
+
+		s.Logger.Info("Finished reading jobs file",
+			zap.String("path", s.Path),
+			zap.Int("read", read),
+			zap.Int("outsideQuery", skipped),
+		)
+		return nil
+	})
+}
+
+// matches applies the date range and filters Detrack would apply
+func matches(job api.Job, from, to time.Time, filters api.JobFilters) bool {
+	if filters.Status != "" && !strings.EqualFold(job.Status, filters.Status) {
+		return false
+	}
+	if filters.Type != "" && !strings.EqualFold(job.Type, filters.Type) {
+		return false
+	}
+
+	date, err := time.ParseInLocation(period.DateLayout, job.Date, from.Location())
+	if err != nil {
+		return true
+	}
+	return !date.Before(from) && date.Before(to)
+}
+
+// readLines decodes one job per line, skipping blank lines
+func readLines(r io.Reader, keep func(api.Job) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var job api.Job
+		if err := json.Unmarshal([]byte(text), &job); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := keep(job); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readDocument decodes a JSON list of jobs, or the "data" list of an object,
+// one job at a time
+func readDocument(r io.Reader, keep func(api.Job) error) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token == json.Delim('{') {
+		if err := seekList(decoder); err != nil {
+			return err
+		}
+	} else if token != json.Delim('[') {
+		return fmt.Errorf("expected a list of jobs or an object, got %v", token)
+	}
+
+	for decoder.More() {
+		var job api.Job
+		if err := decoder.Decode(&job); err != nil {
+			return err
+		}
+		if err := keep(job); err != nil {
+			return err
+		}
+	}
+
+	// Closing ']'; whatever follows the list is not needed
+	_, err = decoder.Token()
+	return err
+}
+
+// seekList moves the decoder into the "data" list of an object
+func seekList(decoder *json.Decoder) error {
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if key, _ := token.(string); key == "data" {
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if token != json.Delim('[') {
+				return fmt.Errorf("%q is not a list", key)
+			}
+			return nil
+		}
+
+		// Skip the value of any other key
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return err
+		}
+	}
+
+	return errors.New(`no "data" list found`)
+}
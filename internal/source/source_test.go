@@ -0,0 +1,100 @@
+package source_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/api"
+	"github.com/jamesphm04/WCP_detrack_monthly_report/internal/source"
+)
+
+var brisbane = time.FixedZone("AEST", 10*60*60)
+
+func TestFileSource(t *testing.T) {
+	february := time.Date(2026, 2, 1, 0, 0, 0, 0, brisbane)
+
+	tests := []struct {
+		name    string
+		file    string
+		filters api.JobFilters
+
+		wantJobs []string
+		wantErr  string
+	}{
+		{
+			name:     "JSON list, filtered by date and status",
+			file:     "jobs.json",
+			filters:  api.JobFilters{Status: "completed"},
+			wantJobs: []string{"a1", "a2", "a5"}, // a5 has an unreadable date and is kept
+		},
+		{
+			name:     "JSON list, filtered by type",
+			file:     "jobs.json",
+			filters:  api.JobFilters{Type: "collection"},
+			wantJobs: []string{"a2"},
+		},
+		{
+			name:     "Detrack response reads the data list after other keys",
+			file:     "response.json",
+			wantJobs: []string{"r1", "r2"},
+		},
+		{
+			name:     "JSONL skips blank lines",
+			file:     "jobs.jsonl",
+			wantJobs: []string{"l1", "l2"},
+		},
+		{name: "JSONL in a .json file is read as JSON", file: "lines.json", wantErr: `no "data" list found`},
+		{name: "JSON report is not a snapshot", file: "report.json", wantErr: `no "data" list found`},
+		{name: "broken JSONL line", file: "broken.jsonl", wantErr: "line 2"},
+		{name: "neither a list nor an object", file: "scalar.json", wantErr: "expected a list of jobs or an object"},
+		{name: "data is not a list", file: "object.json", wantErr: `"data" is not a list`},
+		{name: "missing file", file: "none.json", wantErr: "failed to open jobs file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := source.NewFileSource(zap.NewNop(), filepath.Join("testdata", tt.file))
+
+			jobs, err := s.Jobs(context.Background(), february, february.AddDate(0, 1, 0), tt.filters).Collect()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Jobs = %d jobs, %v, want an error containing %q", len(jobs), err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Jobs: %v", err)
+			}
+
+			ids := make([]string, len(jobs))
+			for i, job := range jobs {
+				ids[i] = job.ID
+			}
+			if !slices.Equal(ids, tt.wantJobs) {
+				t.Errorf("Jobs = %q, want %q", ids, tt.wantJobs)
+			}
+		})
+	}
+}
+
+func TestFileSourceKeepsFields(t *testing.T) {
+	from := time.Date(2026, 2, 2, 0, 0, 0, 0, brisbane)
+	jobs, err := source.NewFileSource(zap.NewNop(), "testdata/response.json").Jobs(context.Background(), from, from.AddDate(0, 0, 1), api.JobFilters{}).Collect()
+	if err != nil {
+		t.Fatalf("Jobs: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs, want r1 only", len(jobs))
+	}
+
+	job := jobs[0]
+	if job.ID != "r1" || job.RunNumber != "WCPNORTH - 8:00AM" || job.JobPrice != "4.50" || job.Type != "Delivery" {
+		t.Errorf("job = %+v, want r1 as in the file", job)
+	}
+}